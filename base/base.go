@@ -1,6 +1,8 @@
 package base
 
 import (
+	"time"
+
 	"github.com/byuoitav/common/structs"
 	ei "github.com/byuoitav/common/v2/events"
 )
@@ -15,28 +17,64 @@ type PublicRoom struct {
 	Blanked           *bool         `json:"blanked,omitempty"`
 	Muted             *bool         `json:"muted,omitempty"`
 	Volume            *int          `json:"volume,omitempty"`
+	VolumeDelta       *int          `json:"volumeDelta,omitempty"`
 	Displays          []Display     `json:"displays,omitempty"`
 	AudioDevices      []AudioDevice `json:"audioDevices,omitempty"`
+	Mismatches        []Mismatch    `json:"mismatches,omitempty"`
+	Trace             *Trace        `json:"trace,omitempty"`
+	LastUpdated       *time.Time    `json:"lastUpdated,omitempty"`
+
+	//ToggleBlanked and ToggleMuted are set when blanked or muted is "toggle" in JSON
+	ToggleBlanked bool `json:"-"`
+	ToggleMuted   bool `json:"-"`
+}
+
+//Mismatch is a requested field that a device didn't report back after a room state change
+type Mismatch struct {
+	Device   string      `json:"device"`
+	Field    string      `json:"field"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual"`
 }
 
 //Device is a struct for inheriting
 type Device struct {
-	Name  string `json:"name,omitempty"`
-	Power string `json:"power,omitempty"`
-	Input string `json:"input,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Power       string `json:"power,omitempty"`
+	Input       string `json:"input,omitempty"`
+	Unreachable bool   `json:"unreachable,omitempty"`
+
+	//LastUpdated and Stale are only set on state served from a room's snapshot. A device is stale when the last
+	//refresh of the snapshot couldn't read it, and LastUpdated is when it last could.
+	LastUpdated *time.Time `json:"lastUpdated,omitempty"`
+	Stale       bool       `json:"stale,omitempty"`
 }
 
 //AudioDevice represents an audio device
 type AudioDevice struct {
 	Device
-	Muted  *bool `json:"muted,omitempty"`
-	Volume *int  `json:"volume,omitempty"`
+	Muted       *bool `json:"muted,omitempty"`
+	Volume      *int  `json:"volume,omitempty"`
+	VolumeDelta *int  `json:"volumeDelta,omitempty"`
+	ToggleMuted bool  `json:"-"`
 }
 
 //Display represents a display
 type Display struct {
 	Device
-	Blanked *bool `json:"blanked,omitempty"`
+	Blanked       *bool `json:"blanked,omitempty"`
+	ToggleBlanked bool  `json:"-"`
+}
+
+//DeviceState is the state of a single device, combining its display and audio state
+type DeviceState struct {
+	Device
+	Blanked       *bool `json:"blanked,omitempty"`
+	Muted         *bool `json:"muted,omitempty"`
+	Volume        *int  `json:"volume,omitempty"`
+	VolumeDelta   *int  `json:"volumeDelta,omitempty"`
+	ToggleBlanked bool  `json:"-"`
+	ToggleMuted   bool  `json:"-"`
 }
 
 //ActionStructure is the internal struct we use to pass commands around once
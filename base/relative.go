@@ -0,0 +1,172 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+//Toggle is the value of power, blanked, or muted that flips it from whatever it currently is
+const Toggle = "toggle"
+
+//toggleBool is a bool field in JSON that can also be "toggle"
+type toggleBool struct {
+	value  **bool
+	toggle *bool
+}
+
+//toggleField returns the JSON form of a bool field that can be toggled, or nil if it isn't set
+func toggleField(value **bool, toggle *bool) *toggleBool {
+	t := &toggleBool{value: value, toggle: toggle}
+	if *value == nil && !*toggle {
+		return nil
+	}
+
+	return t
+}
+
+func (t *toggleBool) MarshalJSON() ([]byte, error) {
+	if *t.toggle {
+		return json.Marshal(Toggle)
+	}
+
+	return json.Marshal(*t.value)
+}
+
+func (t *toggleBool) UnmarshalJSON(b []byte) error {
+	*t.value = nil
+	*t.toggle = false
+
+	if string(b) == "null" {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if s != Toggle {
+			return fmt.Errorf("invalid value %q; expected true, false, or %q", s, Toggle)
+		}
+
+		*t.toggle = true
+		return nil
+	}
+
+	var v bool
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*t.value = &v
+	return nil
+}
+
+//MarshalJSON writes blanked and muted as "toggle" when they're toggled
+func (r PublicRoom) MarshalJSON() ([]byte, error) {
+	type publicRoom PublicRoom
+	return json.Marshal(struct {
+		publicRoom
+		Blanked *toggleBool `json:"blanked,omitempty"`
+		Muted   *toggleBool `json:"muted,omitempty"`
+	}{
+		publicRoom: publicRoom(r),
+		Blanked:    toggleField(&r.Blanked, &r.ToggleBlanked),
+		Muted:      toggleField(&r.Muted, &r.ToggleMuted),
+	})
+}
+
+//UnmarshalJSON accepts "toggle" for blanked and muted
+func (r *PublicRoom) UnmarshalJSON(b []byte) error {
+	type publicRoom PublicRoom
+	aux := struct {
+		*publicRoom
+		Blanked *toggleBool `json:"blanked,omitempty"`
+		Muted   *toggleBool `json:"muted,omitempty"`
+	}{
+		publicRoom: (*publicRoom)(r),
+		Blanked:    &toggleBool{value: &r.Blanked, toggle: &r.ToggleBlanked},
+		Muted:      &toggleBool{value: &r.Muted, toggle: &r.ToggleMuted},
+	}
+
+	return json.Unmarshal(b, &aux)
+}
+
+//MarshalJSON writes muted as "toggle" when it's toggled
+func (a AudioDevice) MarshalJSON() ([]byte, error) {
+	type audioDevice AudioDevice
+	return json.Marshal(struct {
+		audioDevice
+		Muted *toggleBool `json:"muted,omitempty"`
+	}{
+		audioDevice: audioDevice(a),
+		Muted:       toggleField(&a.Muted, &a.ToggleMuted),
+	})
+}
+
+//UnmarshalJSON accepts "toggle" for muted
+func (a *AudioDevice) UnmarshalJSON(b []byte) error {
+	type audioDevice AudioDevice
+	aux := struct {
+		*audioDevice
+		Muted *toggleBool `json:"muted,omitempty"`
+	}{
+		audioDevice: (*audioDevice)(a),
+		Muted:       &toggleBool{value: &a.Muted, toggle: &a.ToggleMuted},
+	}
+
+	return json.Unmarshal(b, &aux)
+}
+
+//MarshalJSON writes blanked as "toggle" when it's toggled
+func (d Display) MarshalJSON() ([]byte, error) {
+	type display Display
+	return json.Marshal(struct {
+		display
+		Blanked *toggleBool `json:"blanked,omitempty"`
+	}{
+		display: display(d),
+		Blanked: toggleField(&d.Blanked, &d.ToggleBlanked),
+	})
+}
+
+//UnmarshalJSON accepts "toggle" for blanked
+func (d *Display) UnmarshalJSON(b []byte) error {
+	type display Display
+	aux := struct {
+		*display
+		Blanked *toggleBool `json:"blanked,omitempty"`
+	}{
+		display: (*display)(d),
+		Blanked: &toggleBool{value: &d.Blanked, toggle: &d.ToggleBlanked},
+	}
+
+	return json.Unmarshal(b, &aux)
+}
+
+//MarshalJSON writes blanked and muted as "toggle" when they're toggled
+func (d DeviceState) MarshalJSON() ([]byte, error) {
+	type deviceState DeviceState
+	return json.Marshal(struct {
+		deviceState
+		Blanked *toggleBool `json:"blanked,omitempty"`
+		Muted   *toggleBool `json:"muted,omitempty"`
+	}{
+		deviceState: deviceState(d),
+		Blanked:     toggleField(&d.Blanked, &d.ToggleBlanked),
+		Muted:       toggleField(&d.Muted, &d.ToggleMuted),
+	})
+}
+
+//UnmarshalJSON accepts "toggle" for blanked and muted
+func (d *DeviceState) UnmarshalJSON(b []byte) error {
+	type deviceState DeviceState
+	aux := struct {
+		*deviceState
+		Blanked *toggleBool `json:"blanked,omitempty"`
+		Muted   *toggleBool `json:"muted,omitempty"`
+	}{
+		deviceState: (*deviceState)(d),
+		Blanked:     &toggleBool{value: &d.Blanked, toggle: &d.ToggleBlanked},
+		Muted:       &toggleBool{value: &d.Muted, toggle: &d.ToggleMuted},
+	}
+
+	return json.Unmarshal(b, &aux)
+}
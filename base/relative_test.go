@@ -0,0 +1,49 @@
+package base
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestToggleRoundTrip(t *testing.T) {
+	body := `{"power":"toggle","blanked":"toggle","volumeDelta":-5,` +
+		`"displays":[{"name":"D1","blanked":"toggle"}],"audioDevices":[{"name":"D1","muted":true,"volumeDelta":3}]}`
+
+	var room PublicRoom
+	if err := json.Unmarshal([]byte(body), &room); err != nil {
+		t.Fatalf("unable to unmarshal: %s", err)
+	}
+
+	if room.Power != Toggle || !room.ToggleBlanked || room.Blanked != nil || room.ToggleMuted {
+		t.Fatalf("unexpected room %+v", room)
+	}
+	if room.VolumeDelta == nil || *room.VolumeDelta != -5 {
+		t.Fatalf("expected a volumeDelta of -5, got %v", room.VolumeDelta)
+	}
+	if len(room.Displays) != 1 || !room.Displays[0].ToggleBlanked {
+		t.Fatalf("expected D1 to be toggled, got %+v", room.Displays)
+	}
+	if a := room.AudioDevices[0]; a.Muted == nil || !*a.Muted || a.ToggleMuted || a.VolumeDelta == nil || *a.VolumeDelta != 3 {
+		t.Fatalf("unexpected audio device %+v", a)
+	}
+
+	b, err := json.Marshal(room)
+	if err != nil {
+		t.Fatalf("unable to marshal: %s", err)
+	}
+
+	for _, want := range []string{`"blanked":"toggle"`, `"volumeDelta":-5`, `"muted":true`, `"volumeDelta":3`} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("expected %s in %s", want, b)
+		}
+	}
+	if strings.Contains(string(b), "toggleBlanked") || strings.Contains(string(b), `"muted":null`) {
+		t.Errorf("unexpected field in %s", b)
+	}
+
+	var d DeviceState
+	if err := json.Unmarshal([]byte(`{"muted":"on"}`), &d); err == nil {
+		t.Fatal("expected an error for a muted value that isn't a bool or toggle")
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/byuoitav/av-api/metrics"
 	"github.com/byuoitav/central-event-system/messenger"
+	"github.com/byuoitav/common/log"
 	"github.com/byuoitav/common/v2/events"
 )
 
@@ -19,6 +21,9 @@ const eventQueueSize = 1000
 var (
 	eventQueue     = make(chan events.Event, eventQueueSize)
 	eventQueueOnce sync.Once
+
+	droppedEvents = metrics.NewCounterVec("av_api_dropped_events_total",
+		"Events dropped because the event queue was full.")
 )
 
 // PublishHealth is a wrapper function to publish an Event that is not an error.
@@ -28,7 +33,7 @@ func PublishHealth(e events.Event) {
 
 // SendEvent sends a pre-made Event to the hub.
 func SendEvent(e events.Event) error {
-	if Messenger == nil {
+	if Messenger == nil && getEventSpool() == nil {
 		return nil
 	}
 
@@ -76,18 +81,42 @@ func queueEvent(e events.Event) {
 	select {
 	case eventQueue <- e:
 	default:
-		// Event delivery is best-effort. Room control should not block when the
-		// central event system is down or the messenger buffer is saturated.
+		// Room control should not block when the central event system is down or
+		// the messenger buffer is saturated, so overflow goes to the spool if there is one.
+		if s := getEventSpool(); s != nil {
+			if err := s.append(e); err == nil {
+				return
+			}
+		}
+
+		droppedEvents.Inc()
 	}
 }
 
 func eventWorker() {
 	for e := range eventQueue {
-		if Messenger == nil {
-			continue
+		deliverEvent(e)
+	}
+}
+
+// deliverEvent sends an event to the hub, or spools it while the hub is unreachable or older events are still waiting to be replayed.
+func deliverEvent(e events.Event) {
+	s := getEventSpool()
+	if s == nil {
+		if Messenger != nil {
+			Messenger.SendEvent(e)
 		}
+		return
+	}
 
+	if messengerConnected() && s.len() == 0 {
 		Messenger.SendEvent(e)
+		return
+	}
+
+	if err := s.append(e); err != nil {
+		log.L.Warnf("[base] unable to spool event: %s", err)
+		droppedEvents.Inc()
 	}
 }
 
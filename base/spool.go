@@ -0,0 +1,354 @@
+package base
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/av-api/metrics"
+	"github.com/byuoitav/common/log"
+	"github.com/byuoitav/common/v2/events"
+)
+
+const (
+	// eventSpoolSegmentSize is the number of events written to a segment file before a new one is started.
+	eventSpoolSegmentSize = 5000
+
+	// eventSpoolMaxEvents bounds the spool; the oldest segment is dropped once it holds more than this.
+	eventSpoolMaxEvents = 100000
+
+	// eventSpoolReplayInterval is how often the spool checks whether the hub is back.
+	eventSpoolReplayInterval = 5 * time.Second
+
+	eventSpoolSegmentPrefix = "events-"
+	eventSpoolSegmentSuffix = ".jsonl"
+)
+
+var (
+	spooledEvents = metrics.NewCounterVec("av_api_spooled_events_total",
+		"Events written to the disk spool because the hub was unreachable or the event queue was full.")
+
+	replayedEvents = metrics.NewCounterVec("av_api_replayed_events_total",
+		"Spooled events sent to the hub after it came back.")
+
+	spoolDepth = metrics.NewGaugeVec("av_api_spooled_events",
+		"Events waiting in the disk spool.")
+)
+
+// spool is the disk-backed event spool, or nil if StartEventSpool hasn't been called.
+var (
+	spool   *eventSpool
+	spoolMu sync.RWMutex
+)
+
+type spoolSegment struct {
+	path  string
+	count int
+}
+
+// eventSpool holds events that couldn't be sent in append-only segment files, oldest first.
+type eventSpool struct {
+	mu          sync.Mutex
+	dir         string
+	segmentSize int
+	maxEvents   int
+	next        int
+	segments    []spoolSegment
+	current     *os.File
+}
+
+// StartEventSpool spools events to segment files in dir while the hub is unreachable, and replays them once it reconnects.
+// Events left in dir from a previous run are replayed too.
+func StartEventSpool(dir string) error {
+	s, err := openEventSpool(dir, eventSpoolSegmentSize, eventSpoolMaxEvents)
+	if err != nil {
+		return err
+	}
+
+	spoolMu.Lock()
+	spool = s
+	spoolMu.Unlock()
+
+	if n := s.len(); n > 0 {
+		log.L.Infof("[base] found %d spooled events in %s", n, dir)
+	}
+
+	go replayWorker(s)
+	return nil
+}
+
+func getEventSpool() *eventSpool {
+	spoolMu.RLock()
+	defer spoolMu.RUnlock()
+
+	return spool
+}
+
+func openEventSpool(dir string, segmentSize int, maxEvents int) (*eventSpool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create event spool: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read event spool: %w", err)
+	}
+
+	s := &eventSpool{
+		dir:         dir,
+		segmentSize: segmentSize,
+		maxEvents:   maxEvents,
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), eventSpoolSegmentPrefix) && strings.HasSuffix(entry.Name(), eventSpoolSegmentSuffix) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var seq int
+		fmt.Sscanf(strings.TrimPrefix(name, eventSpoolSegmentPrefix), "%d", &seq)
+		if seq >= s.next {
+			s.next = seq + 1
+		}
+
+		path := filepath.Join(dir, name)
+		lines, err := readSpoolSegment(path)
+		if err != nil {
+			return nil, err
+		}
+
+		s.segments = append(s.segments, spoolSegment{path: path, count: len(lines)})
+	}
+
+	spoolDepth.Set(float64(s.total()))
+	return s, nil
+}
+
+// total must be called with the lock held, or before the spool is shared.
+func (s *eventSpool) total() int {
+	total := 0
+	for _, segment := range s.segments {
+		total += segment.count
+	}
+
+	return total
+}
+
+func (s *eventSpool) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.total()
+}
+
+// append writes an event to the newest segment, dropping the oldest segment if the spool is over its limit.
+func (s *eventSpool) append(e events.Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("unable to encode event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == nil || s.segments[len(s.segments)-1].count >= s.segmentSize {
+		if err := s.startSegment(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.current.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("unable to spool event: %w", err)
+	}
+
+	s.segments[len(s.segments)-1].count++
+	spooledEvents.Inc()
+
+	for s.total() > s.maxEvents && len(s.segments) > 1 {
+		oldest := s.segments[0]
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			log.L.Warnf("[base] unable to remove spool segment %s: %s", oldest.path, err)
+		}
+
+		s.segments = s.segments[1:]
+		droppedEvents.Add(float64(oldest.count))
+		log.L.Warnf("[base] event spool is full; dropped %d of the oldest events", oldest.count)
+	}
+
+	spoolDepth.Set(float64(s.total()))
+	return nil
+}
+
+// startSegment must be called with the lock held.
+func (s *eventSpool) startSegment() error {
+	if s.current != nil {
+		s.current.Close()
+		s.current = nil
+	}
+
+	path := filepath.Join(s.dir, fmt.Sprintf("%s%020d%s", eventSpoolSegmentPrefix, s.next, eventSpoolSegmentSuffix))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to create spool segment: %w", err)
+	}
+
+	s.next++
+	s.current = f
+	s.segments = append(s.segments, spoolSegment{path: path})
+	return nil
+}
+
+// replay sends spooled events, oldest first, until the spool is empty or send reports the hub is gone.
+// It returns the number of events sent.
+func (s *eventSpool) replay(send func(events.Event) bool) (int, error) {
+	sent := 0
+
+	for {
+		s.mu.Lock()
+		if len(s.segments) == 0 {
+			s.mu.Unlock()
+			return sent, nil
+		}
+
+		segment := s.segments[0]
+		if len(s.segments) == 1 && s.current != nil {
+			// seal the segment being written so new events go to a fresh one
+			s.current.Close()
+			s.current = nil
+		}
+		s.mu.Unlock()
+
+		lines, err := readSpoolSegment(segment.path)
+		if err != nil {
+			return sent, err
+		}
+
+		done := 0
+		for _, line := range lines {
+			var e events.Event
+			if err := json.Unmarshal(line, &e); err != nil {
+				log.L.Warnf("[base] skipping unreadable spooled event: %s", err)
+				done++
+				continue
+			}
+
+			if !send(e) {
+				break
+			}
+
+			done++
+			sent++
+			replayedEvents.Inc()
+		}
+
+		s.mu.Lock()
+		if done < len(lines) {
+			// keep what wasn't sent, unless the segment was dropped while we were sending it
+			if len(s.segments) > 0 && s.segments[0].path == segment.path {
+				err = writeSpoolSegment(segment.path, lines[done:])
+				s.segments[0].count = len(lines) - done
+			}
+			spoolDepth.Set(float64(s.total()))
+			s.mu.Unlock()
+			return sent, err
+		}
+
+		if len(s.segments) > 0 && s.segments[0].path == segment.path {
+			os.Remove(segment.path)
+			s.segments = s.segments[1:]
+		}
+		spoolDepth.Set(float64(s.total()))
+		s.mu.Unlock()
+	}
+}
+
+func readSpoolSegment(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	var lines [][]byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		lines = append(lines, append([]byte(nil), scanner.Bytes()...))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read spool segment %s: %w", path, err)
+	}
+
+	return lines, nil
+}
+
+func writeSpoolSegment(path string, lines [][]byte) error {
+	var b []byte
+	for _, line := range lines {
+		b = append(b, line...)
+		b = append(b, '\n')
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("unable to rewrite spool segment: %w", err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func replayWorker(s *eventSpool) {
+	ticker := time.NewTicker(eventSpoolReplayInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !messengerConnected() || s.len() == 0 {
+			continue
+		}
+
+		log.L.Infof("[base] hub is reachable; replaying %d spooled events", s.len())
+
+		sent, err := s.replay(func(e events.Event) bool {
+			if !messengerConnected() {
+				return false
+			}
+
+			Messenger.SendEvent(e)
+			return true
+		})
+		if err != nil {
+			log.L.Errorf("[base] unable to replay spooled events: %s", err)
+		}
+
+		log.L.Infof("[base] replayed %d spooled events", sent)
+	}
+}
+
+// messengerConnected reports whether the messenger currently has a working connection to the hub.
+func messengerConnected() bool {
+	if Messenger == nil {
+		return false
+	}
+
+	state, ok := Messenger.GetState().(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	return state["state"] == "good"
+}
@@ -0,0 +1,91 @@
+package base
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/byuoitav/common/v2/events"
+)
+
+func spoolTestEvent(i int) events.Event {
+	return events.Event{Key: "power", Value: fmt.Sprintf("%d", i)}
+}
+
+func TestEventSpoolDropsOldestSegment(t *testing.T) {
+	s, err := openEventSpool(t.TempDir(), 2, 4)
+	if err != nil {
+		t.Fatalf("unable to open spool: %s", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := s.append(spoolTestEvent(i)); err != nil {
+			t.Fatalf("unable to append event %d: %s", i, err)
+		}
+	}
+
+	if n := s.len(); n != 3 {
+		t.Fatalf("expected 3 events after dropping the oldest segment, got %d", n)
+	}
+
+	var values []string
+	if _, err := s.replay(func(e events.Event) bool {
+		values = append(values, e.Value)
+		return true
+	}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if fmt.Sprint(values) != "[2 3 4]" {
+		t.Fatalf("expected the newest events in order, got %v", values)
+	}
+	if n := s.len(); n != 0 {
+		t.Fatalf("expected an empty spool after replaying, got %d", n)
+	}
+}
+
+func TestEventSpoolSurvivesRestartAndPartialReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := openEventSpool(dir, 10, 100)
+	if err != nil {
+		t.Fatalf("unable to open spool: %s", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := s.append(spoolTestEvent(i)); err != nil {
+			t.Fatalf("unable to append event %d: %s", i, err)
+		}
+	}
+
+	sent, err := s.replay(func(e events.Event) bool {
+		return e.Value != "2"
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if sent != 2 {
+		t.Fatalf("expected 2 events sent before the hub went away, got %d", sent)
+	}
+
+	reopened, err := openEventSpool(dir, 10, 100)
+	if err != nil {
+		t.Fatalf("unable to reopen spool: %s", err)
+	}
+	if n := reopened.len(); n != 2 {
+		t.Fatalf("expected 2 events left after reopening, got %d", n)
+	}
+
+	if err := reopened.append(spoolTestEvent(4)); err != nil {
+		t.Fatalf("unable to append after reopening: %s", err)
+	}
+
+	var values []string
+	reopened.replay(func(e events.Event) bool {
+		values = append(values, e.Value)
+		return true
+	})
+
+	if fmt.Sprint(values) != "[2 3 4]" {
+		t.Fatalf("expected remaining events in order, got %v", values)
+	}
+}
@@ -0,0 +1,60 @@
+package base
+
+import "time"
+
+//Trace is a timeline of a single room state change
+type Trace struct {
+	Started    time.Time        `json:"started"`
+	Finished   time.Time        `json:"finished"`
+	Evaluators []EvaluatorTrace `json:"evaluators,omitempty"`
+	Reconciler *ReconcilerTrace `json:"reconciler,omitempty"`
+	Actions    []ActionTrace    `json:"actions,omitempty"`
+}
+
+//EvaluatorTrace records how long a command evaluator took and how many actions it generated
+type EvaluatorTrace struct {
+	Evaluator string    `json:"evaluator"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	Actions   int       `json:"actions"`
+	Error     string    `json:"error,omitempty"`
+}
+
+//ReconcilerTrace records the DAG a reconciler built from the generated actions
+type ReconcilerTrace struct {
+	Reconciler string          `json:"reconciler"`
+	Started    time.Time       `json:"started"`
+	Finished   time.Time       `json:"finished"`
+	Count      int             `json:"count"`
+	Actions    []PlannedAction `json:"actions,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+//PlannedAction is a serializable view of an action in a reconciled DAG, as planned, traced, and audited
+type PlannedAction struct {
+	Action              string            `json:"action"`
+	GeneratingEvaluator string            `json:"generatingEvaluator"`
+	Device              string            `json:"device"`
+	DestinationDevice   string            `json:"destinationDevice,omitempty"`
+	URL                 string            `json:"url,omitempty"`
+	URLError            string            `json:"urlError,omitempty"`
+	Parameters          map[string]string `json:"parameters,omitempty"`
+	DeviceSpecific      bool              `json:"deviceSpecific"`
+	Overridden          bool              `json:"overridden"`
+	Children            []PlannedAction   `json:"children,omitempty"`
+}
+
+//ActionTrace records the execution of a single action, or why it was skipped
+type ActionTrace struct {
+	Action            string     `json:"action"`
+	Device            string     `json:"device"`
+	DestinationDevice string     `json:"destinationDevice,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Started           *time.Time `json:"started,omitempty"`
+	Finished          *time.Time `json:"finished,omitempty"`
+	StatusCode        int        `json:"statusCode,omitempty"`
+	Response          string     `json:"response,omitempty"`
+	Error             string     `json:"error,omitempty"`
+	Skipped           bool       `json:"skipped,omitempty"`
+	Reason            string     `json:"reason,omitempty"`
+}
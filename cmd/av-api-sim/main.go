@@ -0,0 +1,247 @@
+// Command av-api-sim runs a room state request against a room's configuration without any hardware. It prints the
+// reconciled DAG the request would execute and, given a fixture file of status responses, the room state the status
+// evaluators would report.
+//
+// Usage:
+//
+//	av-api-sim -room room.json [-request body.json] [-fixtures status.json]
+//
+// The room file is a structs.Room, as stored in the configuration database, and the request file is the base.PublicRoom
+// body of a PUT to /buildings/:building/rooms/:room. The fixture file maps device IDs to command IDs to the JSON body the
+// device's microservice would return for that command, e.g.
+//
+//	{"ITB-1101-D1": {"STATUS_Power": {"power": "on"}}}
+//
+// A request with a volumeDelta, or power, blanked, or muted set to "toggle", needs a fixture file: its relative fields
+// are resolved against the room state the fixtures report before it's planned.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/config"
+	"github.com/byuoitav/av-api/config/validate"
+	"github.com/byuoitav/av-api/state"
+	se "github.com/byuoitav/av-api/statusevaluators"
+	"github.com/byuoitav/av-api/transport"
+	"github.com/byuoitav/common/structs"
+)
+
+const requestor = "av-api-sim"
+
+// Result is what the simulator prints.
+type Result struct {
+	Validation validate.Report      `json:"validation"`
+	Plan       *state.RoomStatePlan `json:"plan,omitempty"`
+	Status     *base.PublicRoom     `json:"status,omitempty"`
+	Unanswered []string             `json:"unanswered,omitempty"`
+}
+
+// Fixtures are the responses the simulated microservices give, by device ID and then command ID.
+type Fixtures map[string]map[string]json.RawMessage
+
+type fixtureTransport struct {
+	fixtures Fixtures
+
+	mu         sync.Mutex
+	unanswered map[string]bool
+}
+
+func (f *fixtureTransport) Send(ctx context.Context, request transport.Request) (transport.Response, error) {
+	if body, ok := f.fixtures[request.Device.ID][request.Command.ID]; ok {
+		return transport.Response{StatusCode: http.StatusOK, Body: body}, nil
+	}
+
+	f.mu.Lock()
+	f.unanswered[fmt.Sprintf("%s %s", request.Device.ID, request.Command.ID)] = true
+	f.mu.Unlock()
+
+	return transport.Response{
+		StatusCode: http.StatusNotFound,
+		Body:       []byte(fmt.Sprintf("no fixture for %s on %s", request.Command.ID, request.Device.ID)),
+	}, nil
+}
+
+func (f *fixtureTransport) unansweredCommands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var unanswered []string
+	for key := range f.unanswered {
+		unanswered = append(unanswered, key)
+	}
+
+	sort.Strings(unanswered)
+	return unanswered
+}
+
+// useFixtures sends every command in the room, whatever transport it selects, to the fixtures.
+func useFixtures(room structs.Room, fixtures Fixtures) *fixtureTransport {
+	f := &fixtureTransport{
+		fixtures:   fixtures,
+		unanswered: make(map[string]bool),
+	}
+
+	names := map[string]bool{
+		transport.HTTPGet:  true,
+		transport.HTTPPost: true,
+		transport.HTTPPut:  true,
+		transport.Driver:   true,
+	}
+
+	for _, device := range room.Devices {
+		for _, command := range device.Type.Commands {
+			for _, tag := range append(command.Tags, command.Microservice.Tags...) {
+				if strings.HasPrefix(tag, transport.TagPrefix) {
+					names[strings.TrimPrefix(tag, transport.TagPrefix)] = true
+				}
+			}
+		}
+	}
+
+	for name := range names {
+		transport.Register(name, f)
+	}
+
+	return f
+}
+
+// Simulate plans the request against the room and, if fixtures are given, evaluates the room's status from them.
+func Simulate(ctx context.Context, room structs.Room, request *base.PublicRoom, fixtures Fixtures) (Result, error) {
+	config.UseRooms(room)
+
+	result := Result{
+		Validation: validate.Room(room),
+	}
+
+	if fixtures != nil {
+		f := useFixtures(room, fixtures)
+
+		commands, count, err := state.GenerateStatusCommands(room, se.StatusEvaluatorMap)
+		if err != nil {
+			return result, fmt.Errorf("unable to generate status commands: %w", err)
+		}
+
+		responses, err := state.RunStatusCommandsWithContext(ctx, commands)
+		if err != nil {
+			return result, fmt.Errorf("unable to run status commands: %w", err)
+		}
+
+		status, err := state.EvaluateResponsesWithContext(ctx, room, responses, count)
+		result.Unanswered = f.unansweredCommands()
+		if err != nil {
+			return result, fmt.Errorf("unable to evaluate responses: %w", err)
+		}
+
+		split := strings.SplitN(room.ID, "-", 2)
+		if len(split) == 2 {
+			status.Building = split[0]
+			status.Room = split[1]
+		}
+
+		result.Status = &status
+	}
+
+	if request != nil {
+		target := *request
+
+		// relative fields are resolved against the status the fixtures report, like the api resolves them against the room
+		if state.HasRelativeState(target) {
+			if result.Status == nil {
+				return result, errors.New("unable to plan volumeDelta or toggle values without -fixtures to read the room's current state from")
+			}
+
+			var err error
+			target, err = state.ResolveRelativeState(*result.Status, target)
+			if err != nil {
+				return result, fmt.Errorf("unable to resolve relative fields: %w", err)
+			}
+		}
+
+		actions, count, err := state.GenerateActionsWithContext(ctx, room, target, requestor)
+		if err != nil {
+			return result, fmt.Errorf("unable to generate actions: %w", err)
+		}
+
+		plan := state.BuildRoomStatePlan(actions, count)
+		result.Plan = &plan
+	}
+
+	return result, nil
+}
+
+func readJSON(path string, v interface{}) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func run(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("av-api-sim", flag.ContinueOnError)
+	roomPath := flags.String("room", "", "path to the room's configuration (a structs.Room)")
+	requestPath := flags.String("request", "", "path to the request body (a base.PublicRoom)")
+	fixturesPath := flags.String("fixtures", "", "path to the status responses to answer status commands with")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if len(*roomPath) == 0 {
+		return fmt.Errorf("-room is required")
+	}
+
+	var room structs.Room
+	if err := readJSON(*roomPath, &room); err != nil {
+		return err
+	}
+
+	var request *base.PublicRoom
+	if len(*requestPath) > 0 {
+		request = &base.PublicRoom{}
+		if err := readJSON(*requestPath, request); err != nil {
+			return err
+		}
+	}
+
+	var fixtures Fixtures
+	if len(*fixturesPath) > 0 {
+		if err := readJSON(*fixturesPath, &fixtures); err != nil {
+			return err
+		}
+	}
+
+	result, err := Simulate(context.Background(), room, request, fixtures)
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if eerr := enc.Encode(result); eerr != nil {
+		return eerr
+	}
+
+	return err
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "av-api-sim: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/common/structs"
+)
+
+func command(id string, path string) structs.Command {
+	return structs.Command{
+		ID:           id,
+		Microservice: structs.Microservice{Address: "http://localhost:8005"},
+		Endpoint:     structs.Endpoint{Path: path},
+	}
+}
+
+func display(id string) structs.Device {
+	return structs.Device{
+		ID:      id,
+		Name:    id[len("ITB-1101-"):],
+		Address: id + ".byu.edu",
+		Roles:   []structs.Role{{ID: "VideoOut"}},
+		Type: structs.DeviceType{
+			Output: true,
+			Commands: []structs.Command{
+				command("PowerOn", "/:address/power/on"),
+				command("STATUS_Power", "/:address/power/status"),
+			},
+		},
+	}
+}
+
+func TestSimulatePlansAndEvaluatesFixtures(t *testing.T) {
+	room := structs.Room{
+		ID: "ITB-1101",
+		Configuration: structs.RoomConfiguration{
+			Description: "Default",
+			Evaluators: []structs.Evaluator{
+				{CodeKey: "PowerOnDefault"},
+				{CodeKey: "STATUS_PowerDefault"},
+			},
+		},
+		Devices: []structs.Device{display("ITB-1101-D1"), display("ITB-1101-D2")},
+	}
+
+	request := &base.PublicRoom{Building: "ITB", Room: "1101", Power: "on"}
+	fixtures := Fixtures{
+		"ITB-1101-D1": {"STATUS_Power": json.RawMessage(`{"power": "on"}`)},
+	}
+
+	result, err := Simulate(context.Background(), room, request, fixtures)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if result.Plan == nil || result.Plan.Count != 2 {
+		t.Fatalf("expected a plan powering on both displays, got %+v", result.Plan)
+	}
+	for _, action := range result.Plan.Actions {
+		if action.Action != "PowerOn" || action.URL != "http://localhost:8005/"+action.Device+".byu.edu/power/on" {
+			t.Fatalf("unexpected planned action %+v", action)
+		}
+	}
+
+	if result.Status == nil || len(result.Status.Displays) != 1 {
+		t.Fatalf("expected status for the display with a fixture, got %+v", result.Status)
+	}
+	if d := result.Status.Displays[0]; d.Name != "D1" || d.Power != "on" {
+		t.Fatalf("unexpected display status %+v", d)
+	}
+
+	if len(result.Unanswered) != 1 || result.Unanswered[0] != "ITB-1101-D2 STATUS_Power" {
+		t.Fatalf("expected D2's status command to be unanswered, got %v", result.Unanswered)
+	}
+}
+
+func TestSimulateResolvesRelativeFieldsAgainstFixtures(t *testing.T) {
+	room := structs.Room{
+		ID: "ITB-1101",
+		Configuration: structs.RoomConfiguration{
+			Description: "Default",
+			Evaluators: []structs.Evaluator{
+				{CodeKey: "PowerOnDefault"},
+				{CodeKey: "STATUS_PowerDefault"},
+			},
+		},
+		Devices: []structs.Device{display("ITB-1101-D1")},
+	}
+
+	request := &base.PublicRoom{Building: "ITB", Room: "1101", Power: base.Toggle}
+
+	if _, err := Simulate(context.Background(), room, request, nil); err == nil {
+		t.Fatal("expected an error planning a toggle without fixtures")
+	}
+
+	fixtures := Fixtures{
+		"ITB-1101-D1": {"STATUS_Power": json.RawMessage(`{"power": "standby"}`)},
+	}
+
+	result, err := Simulate(context.Background(), room, request, fixtures)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if result.Plan == nil || result.Plan.Count != 1 || result.Plan.Actions[0].Action != "PowerOn" {
+		t.Fatalf("expected a plan powering on the display that's off, got %+v", result.Plan)
+	}
+}
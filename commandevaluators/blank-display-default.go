@@ -6,7 +6,7 @@ import (
 	"strings"
 
 	"github.com/byuoitav/av-api/base"
-	"github.com/byuoitav/common/db"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/common/log"
 	"github.com/byuoitav/common/structs"
 	"github.com/byuoitav/common/v2/events"
@@ -75,7 +75,7 @@ func (p *BlankDisplayDefault) Evaluate(dbRoom structs.Room, room base.PublicRoom
 				if structs.HasRole(device, "MirrorMaster") {
 					for _, port := range device.Ports {
 						if port.ID == "mirror" {
-							DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+							DX, err := config.GetDevice(port.DestinationDevice)
 							if err != nil {
 								return []base.ActionStructure{}, 0, err
 							}
@@ -153,7 +153,7 @@ func (p *BlankDisplayDefault) Evaluate(dbRoom structs.Room, room base.PublicRoom
 			if structs.HasRole(device, "MirrorMaster") {
 				for _, port := range device.Ports {
 					if port.ID == "mirror" {
-						DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+						DX, err := config.GetDevice(port.DestinationDevice)
 						if err != nil {
 							return []base.ActionStructure{}, 0, err
 						}
@@ -8,7 +8,7 @@ import (
 	"github.com/byuoitav/common/log"
 
 	"github.com/byuoitav/av-api/base"
-	"github.com/byuoitav/common/db"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/common/structs"
 	"github.com/byuoitav/common/v2/events"
 )
@@ -45,7 +45,7 @@ func (c *ChangeVideoInputVideoSwitcher) Evaluate(dbRoom structs.Room, room base.
 			if structs.HasRole(device, "MirrorMaster") {
 				for _, port := range device.Ports {
 					if port.ID == "mirror" {
-						DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+						DX, err := config.GetDevice(port.DestinationDevice)
 						if err != nil {
 							return []base.ActionStructure{}, 0, err
 						}
@@ -92,7 +92,7 @@ func (c *ChangeVideoInputVideoSwitcher) Evaluate(dbRoom structs.Room, room base.
 				if structs.HasRole(device, "MirrorMaster") {
 					for _, port := range device.Ports {
 						if port.ID == "mirror" {
-							DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+							DX, err := config.GetDevice(port.DestinationDevice)
 							if err != nil {
 								return actionList, len(actionList), err
 							}
@@ -137,7 +137,7 @@ func (c *ChangeVideoInputVideoSwitcher) Evaluate(dbRoom structs.Room, room base.
 				if structs.HasRole(device, "MirrorMaster") {
 					for _, port := range device.Ports {
 						if port.ID == "mirror" {
-							DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+							DX, err := config.GetDevice(port.DestinationDevice)
 							if err != nil {
 								return []base.ActionStructure{}, 0, err
 							}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 
 	"github.com/byuoitav/av-api/base"
-	"github.com/byuoitav/common/db"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/common/structs"
 )
 
@@ -47,7 +47,7 @@ func getDevice(devs []structs.Device, d string, room string, building string) (d
 	var device structs.Device
 
 	deviceID := fmt.Sprintf("%v-%v-%v", building, room, d)
-	device, err = db.GetDB().GetDevice(deviceID)
+	device, err = config.GetDevice(deviceID)
 	if err != nil {
 		return
 	}
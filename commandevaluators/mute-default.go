@@ -8,7 +8,7 @@ import (
 	"github.com/byuoitav/common/log"
 
 	"github.com/byuoitav/av-api/base"
-	"github.com/byuoitav/common/db"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/common/structs"
 	"github.com/byuoitav/common/v2/events"
 )
@@ -73,7 +73,7 @@ func (p *MuteDefault) Evaluate(dbRoom structs.Room, room base.PublicRoom, reques
 				if structs.HasRole(device, "MirrorMaster") {
 					for _, port := range device.Ports {
 						if port.ID == "mirror" {
-							DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+							DX, err := config.GetDevice(port.DestinationDevice)
 							if err != nil {
 								return actions, len(actions), err
 							}
@@ -139,7 +139,7 @@ func (p *MuteDefault) Evaluate(dbRoom structs.Room, room base.PublicRoom, reques
 			if structs.HasRole(device, "MirrorMaster") {
 				for _, port := range device.Ports {
 					if port.ID == "mirror" {
-						DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+						DX, err := config.GetDevice(port.DestinationDevice)
 						if err != nil {
 							return []base.ActionStructure{}, 0, err
 						}
@@ -19,7 +19,7 @@ import (
 	"github.com/byuoitav/common/log"
 
 	"github.com/byuoitav/av-api/base"
-	"github.com/byuoitav/common/db"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/common/structs"
 	ei "github.com/byuoitav/common/v2/events"
 )
@@ -111,7 +111,7 @@ func (p *MuteDSP) Evaluate(dbRoom structs.Room, room base.PublicRoom, requestor
 				if structs.HasRole(device, "MirrorMaster") {
 					for _, port := range device.Ports {
 						if port.ID == "mirror" {
-							DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+							DX, err := config.GetDevice(port.DestinationDevice)
 							if err != nil {
 								return actions, len(actions), err
 							}
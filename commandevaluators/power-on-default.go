@@ -8,7 +8,7 @@ import (
 	"github.com/byuoitav/common/log"
 
 	"github.com/byuoitav/av-api/base"
-	"github.com/byuoitav/common/db"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/common/structs"
 	"github.com/byuoitav/common/v2/events"
 	"github.com/fatih/color"
@@ -183,7 +183,7 @@ func (p *PowerOnDefault) evaluateDevice(device base.Device,
 			if structs.HasRole(dev, "MirrorMaster") {
 				for _, port := range dev.Ports {
 					if port.ID == "mirror" {
-						DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+						DX, err := config.GetDevice(port.DestinationDevice)
 						if err != nil {
 							return actions, err
 						}
@@ -8,7 +8,7 @@ import (
 	"github.com/byuoitav/common/log"
 
 	"github.com/byuoitav/av-api/base"
-	"github.com/byuoitav/common/db"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/common/structs"
 	"github.com/byuoitav/common/v2/events"
 )
@@ -74,7 +74,7 @@ func (*SetVolumeDefault) Evaluate(dbRoom structs.Room, room base.PublicRoom, req
 				if structs.HasRole(device, "MirrorMaster") {
 					for _, port := range device.Ports {
 						if port.ID == "mirror" {
-							DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+							DX, err := config.GetDevice(port.DestinationDevice)
 							if err != nil {
 								return []base.ActionStructure{}, 0, err
 							}
@@ -155,7 +155,7 @@ func (*SetVolumeDefault) Evaluate(dbRoom structs.Room, room base.PublicRoom, req
 				if structs.HasRole(device, "MirrorMaster") {
 					for _, port := range device.Ports {
 						if port.ID == "mirror" {
-							DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+							DX, err := config.GetDevice(port.DestinationDevice)
 							if err != nil {
 								return actions, len(actions), err
 							}
@@ -197,6 +197,25 @@ func (*SetVolumeDefault) Evaluate(dbRoom structs.Room, room base.PublicRoom, req
 	return actions, len(actions), nil
 }
 
+// The volume levels SetVolumeDefault and SetVolumeDSP accept.
+const (
+	MaximumVolume = 100
+	MinimumVolume = 0
+)
+
+// ClampVolume limits a volume level to the levels validateSetVolumeMaxMin accepts.
+func ClampVolume(level int) int {
+	if level > MaximumVolume {
+		return MaximumVolume
+	}
+
+	if level < MinimumVolume {
+		return MinimumVolume
+	}
+
+	return level
+}
+
 func validateSetVolumeMaxMin(action base.ActionStructure, maximum int, minimum int) error {
 	level, err := strconv.Atoi(action.Parameters["level"])
 	if err != nil {
@@ -213,8 +232,8 @@ func validateSetVolumeMaxMin(action base.ActionStructure, maximum int, minimum i
 
 //Validate returns an error if the volume is greater than 100 or less than 0
 func (p *SetVolumeDefault) Validate(action base.ActionStructure) error {
-	maximum := 100
-	minimum := 0
+	maximum := MaximumVolume
+	minimum := MinimumVolume
 
 	return validateSetVolumeMaxMin(action, maximum, minimum)
 
@@ -20,7 +20,7 @@ import (
 	"github.com/byuoitav/common/log"
 
 	"github.com/byuoitav/av-api/base"
-	"github.com/byuoitav/common/db"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/common/structs"
 
 	ei "github.com/byuoitav/common/v2/events"
@@ -102,7 +102,7 @@ func (p *SetVolumeDSP) Evaluate(dbRoom structs.Room, room base.PublicRoom, reque
 					if structs.HasRole(device, "MirrorMaster") {
 						for _, port := range device.Ports {
 							if port.ID == "mirror" {
-								DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+								DX, err := config.GetDevice(port.DestinationDevice)
 								if err != nil {
 									return actions, len(actions), err
 								}
@@ -148,8 +148,8 @@ func (p *SetVolumeDSP) Evaluate(dbRoom structs.Room, room base.PublicRoom, reque
 
 // Validate verifies that the action information is correct.
 func (p *SetVolumeDSP) Validate(action base.ActionStructure) (err error) {
-	maximum := 100
-	minimum := 0
+	maximum := MaximumVolume
+	minimum := MinimumVolume
 
 	level, err := strconv.Atoi(action.Parameters["level"])
 	if err != nil {
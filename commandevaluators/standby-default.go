@@ -8,7 +8,7 @@ import (
 	"github.com/byuoitav/common/log"
 
 	"github.com/byuoitav/av-api/base"
-	"github.com/byuoitav/common/db"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/common/structs"
 	"github.com/byuoitav/common/v2/events"
 )
@@ -168,7 +168,7 @@ func (s *StandbyDefault) evaluateDevice(device base.Device, destination base.Des
 			if structs.HasRole(dev, "MirrorMaster") {
 				for _, port := range dev.Ports {
 					if port.ID == "mirror" {
-						DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+						DX, err := config.GetDevice(port.DestinationDevice)
 						if err != nil {
 							return actions, err
 						}
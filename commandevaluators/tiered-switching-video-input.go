@@ -10,8 +10,8 @@ import (
 	"github.com/byuoitav/common/log"
 
 	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/av-api/statusevaluators"
-	"github.com/byuoitav/common/db"
 	"github.com/byuoitav/common/structs"
 	"github.com/byuoitav/common/v2/events"
 	"github.com/fatih/color"
@@ -166,7 +166,7 @@ func (c *ChangeVideoInputTieredSwitchers) Evaluate(dbRoom structs.Room, room bas
 			if structs.HasRole(display, "MirrorMaster") {
 				for _, port := range display.Ports {
 					if port.ID == "mirror" {
-						DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+						DX, err := config.GetDevice(port.DestinationDevice)
 						if err != nil {
 							return actions, len(actions), err
 						}
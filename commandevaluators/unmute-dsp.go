@@ -20,7 +20,7 @@ import (
 	"github.com/byuoitav/common/log"
 
 	"github.com/byuoitav/av-api/base"
-	"github.com/byuoitav/common/db"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/common/structs"
 	"github.com/byuoitav/common/v2/events"
 )
@@ -102,7 +102,7 @@ func (p *UnMuteDSP) Evaluate(dbRoom structs.Room, room base.PublicRoom, requesto
 					if structs.HasRole(device, "MirrorMaster") {
 						for _, port := range device.Ports {
 							if port.ID == "mirror" {
-								DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+								DX, err := config.GetDevice(port.DestinationDevice)
 								if err != nil {
 									return actions, len(actions), err
 								}
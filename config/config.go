@@ -0,0 +1,377 @@
+// Package config gets room and device configuration from the configuration database. Configuration is cached in
+// memory for a short time, and on room systems, the last configuration fetched for each room is kept on local disk
+// and used whenever the database can't be reached.
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/av-api/helpers"
+	"github.com/byuoitav/common/db"
+	"github.com/byuoitav/common/log"
+	"github.com/byuoitav/common/structs"
+)
+
+// Sources of a room's configuration.
+const (
+	SourceDatabase = "database"
+	SourceDisk     = "disk"
+)
+
+// DefaultCacheTTL is how long configuration is cached in memory unless SetCacheTTL is called.
+const DefaultCacheTTL = 30 * time.Second
+
+// RefreshInterval is how often a room system refreshes its room's configuration.
+const RefreshInterval = 5 * time.Minute
+
+// diskSaveInterval is how often an unchanged configuration is rewritten to disk to record that it is still current.
+const diskSaveInterval = 5 * time.Minute
+
+var (
+	fetchRoom = func(roomID string) (structs.Room, error) {
+		return db.GetDB().GetRoom(roomID)
+	}
+
+	fetchDevice = func(deviceID string) (structs.Device, error) {
+		return db.GetDB().GetDevice(deviceID)
+	}
+)
+
+// UseDB gets configuration from database instead of the database at DB_ADDRESS.
+func UseDB(database db.DB) {
+	fetchRoom = database.GetRoom
+	fetchDevice = database.GetDevice
+
+	clearCache()
+}
+
+// UseRooms serves configuration from a fixed set of rooms instead of the configuration database. It's meant for
+// tools that run without a database, like the simulator.
+func UseRooms(fixed ...structs.Room) {
+	byID := make(map[string]structs.Room)
+	devices := make(map[string]structs.Device)
+	for _, room := range fixed {
+		byID[room.ID] = room
+		for _, device := range room.Devices {
+			devices[device.ID] = device
+		}
+	}
+
+	fetchRoom = func(roomID string) (structs.Room, error) {
+		room, ok := byID[roomID]
+		if !ok {
+			return structs.Room{}, fmt.Errorf("no room %s", roomID)
+		}
+
+		return room, nil
+	}
+
+	fetchDevice = func(deviceID string) (structs.Device, error) {
+		device, ok := devices[deviceID]
+		if !ok {
+			return structs.Device{}, fmt.Errorf("no device %s", deviceID)
+		}
+
+		return device, nil
+	}
+
+	clearCache()
+}
+
+// Info describes where a room's configuration last came from.
+type Info struct {
+	Source    string    `json:"source"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// Age returns how long ago the configuration was fetched from the database.
+func (i Info) Age() time.Duration {
+	return time.Since(i.FetchedAt)
+}
+
+type savedRoom struct {
+	FetchedAt time.Time    `json:"fetchedAt"`
+	Room      structs.Room `json:"room"`
+}
+
+type cachedRoom struct {
+	room      structs.Room
+	expiresAt time.Time
+}
+
+type cachedDevice struct {
+	device    structs.Device
+	expiresAt time.Time
+}
+
+var rooms = struct {
+	sync.Mutex
+	ttl     time.Duration
+	info    map[string]Info
+	saved   map[string]savedRoom
+	cache   map[string]cachedRoom
+	devices map[string]cachedDevice
+}{
+	ttl:     DefaultCacheTTL,
+	info:    make(map[string]Info),
+	saved:   make(map[string]savedRoom),
+	cache:   make(map[string]cachedRoom),
+	devices: make(map[string]cachedDevice),
+}
+
+// SetCacheTTL sets how long configuration is cached in memory. A TTL of zero turns the cache off.
+func SetCacheTTL(ttl time.Duration) {
+	rooms.Lock()
+	defer rooms.Unlock()
+
+	rooms.ttl = ttl
+	rooms.cache = make(map[string]cachedRoom)
+	rooms.devices = make(map[string]cachedDevice)
+}
+
+func clearCache() {
+	rooms.Lock()
+	defer rooms.Unlock()
+
+	rooms.cache = make(map[string]cachedRoom)
+	rooms.devices = make(map[string]cachedDevice)
+}
+
+// Invalidate drops a room and its devices from the in-memory cache, so they're fetched again on next use.
+func Invalidate(roomID string) {
+	rooms.Lock()
+	defer rooms.Unlock()
+
+	delete(rooms.cache, roomID)
+	for id := range rooms.devices {
+		if strings.HasPrefix(id, roomID+"-") {
+			delete(rooms.devices, id)
+		}
+	}
+}
+
+func cachedRoomFor(roomID string) (structs.Room, bool) {
+	rooms.Lock()
+	defer rooms.Unlock()
+
+	cached, ok := rooms.cache[roomID]
+	if !ok || time.Now().After(cached.expiresAt) {
+		return structs.Room{}, false
+	}
+
+	return cached.room, true
+}
+
+func cachedDeviceFor(deviceID string) (structs.Device, bool) {
+	rooms.Lock()
+	defer rooms.Unlock()
+
+	cached, ok := rooms.devices[deviceID]
+	if !ok || time.Now().After(cached.expiresAt) {
+		return structs.Device{}, false
+	}
+
+	return cached.device, true
+}
+
+// cacheRoom keeps a room, and each of its devices, in memory until the TTL passes.
+func cacheRoom(roomID string, room structs.Room) {
+	rooms.Lock()
+	defer rooms.Unlock()
+
+	if rooms.ttl <= 0 {
+		return
+	}
+
+	expiresAt := time.Now().Add(rooms.ttl)
+	rooms.cache[roomID] = cachedRoom{room: room, expiresAt: expiresAt}
+	for _, device := range room.Devices {
+		rooms.devices[device.ID] = cachedDevice{device: device, expiresAt: expiresAt}
+	}
+}
+
+func cacheDevice(device structs.Device) {
+	rooms.Lock()
+	defer rooms.Unlock()
+
+	if rooms.ttl <= 0 {
+		return
+	}
+
+	rooms.devices[device.ID] = cachedDevice{device: device, expiresAt: time.Now().Add(rooms.ttl)}
+}
+
+// offline reports whether configuration is kept on disk, which is only done on room systems.
+func offline() bool {
+	return len(os.Getenv("ROOM_SYSTEM")) > 0
+}
+
+// SystemRoomID returns the ID of the room a room system is in, from its SYSTEM_ID.
+func SystemRoomID() string {
+	split := strings.Split(os.Getenv("SYSTEM_ID"), "-")
+	if len(split) < 2 {
+		return ""
+	}
+
+	return fmt.Sprintf("%s-%s", split[0], split[1])
+}
+
+// GetRoom gets a room's configuration from the cache or the database. On room systems, the configuration saved on disk
+// is returned if the database can't be reached.
+func GetRoom(roomID string) (structs.Room, error) {
+	if room, ok := cachedRoomFor(roomID); ok {
+		return room, nil
+	}
+
+	room, err := fetchRoom(roomID)
+	if err == nil {
+		now := time.Now()
+		setInfo(roomID, Info{Source: SourceDatabase, FetchedAt: now})
+		cacheRoom(roomID, room)
+
+		if offline() {
+			saveRoom(roomID, room, now)
+		}
+
+		return room, nil
+	}
+
+	if !offline() {
+		return room, err
+	}
+
+	saved, serr := loadRoom(roomID)
+	if serr != nil {
+		log.L.Debugf("[config] no saved configuration for %s: %s", roomID, serr)
+		return structs.Room{}, err
+	}
+
+	log.L.Warnf("[config] unable to get %s from the database, using configuration saved %s ago: %s", roomID, time.Since(saved.FetchedAt).Round(time.Second), err)
+	setInfo(roomID, Info{Source: SourceDisk, FetchedAt: saved.FetchedAt})
+
+	return saved.Room, nil
+}
+
+// GetDevice gets a device's configuration from the cache or the database. On room systems, the device is found in
+// its room's saved configuration if the database can't be reached.
+func GetDevice(deviceID string) (structs.Device, error) {
+	if device, ok := cachedDeviceFor(deviceID); ok {
+		return device, nil
+	}
+
+	device, err := fetchDevice(deviceID)
+	if err == nil {
+		cacheDevice(device)
+		return device, nil
+	}
+
+	if !offline() {
+		return device, err
+	}
+
+	saved, serr := loadRoom((&structs.Device{ID: deviceID}).GetDeviceRoomID())
+	if serr != nil {
+		return device, err
+	}
+
+	for _, d := range saved.Room.Devices {
+		if d.ID == deviceID {
+			log.L.Warnf("[config] unable to get %s from the database, using saved configuration: %s", deviceID, err)
+			return d, nil
+		}
+	}
+
+	return device, err
+}
+
+// GetInfo returns where a room's configuration last came from, and whether it has been fetched at all.
+func GetInfo(roomID string) (Info, bool) {
+	rooms.Lock()
+	defer rooms.Unlock()
+
+	info, ok := rooms.info[roomID]
+	return info, ok
+}
+
+func setInfo(roomID string, info Info) {
+	rooms.Lock()
+	defer rooms.Unlock()
+
+	rooms.info[roomID] = info
+}
+
+func roomPath(roomID string) string {
+	return helpers.DataPath(fmt.Sprintf("config/%s.json", roomID))
+}
+
+// saveRoom writes a room's configuration to disk if it changed or hasn't been written in a while.
+func saveRoom(roomID string, room structs.Room, fetchedAt time.Time) {
+	rooms.Lock()
+	defer rooms.Unlock()
+
+	previous, ok := rooms.saved[roomID]
+	if ok && fetchedAt.Sub(previous.FetchedAt) < diskSaveInterval && sameRoom(previous.Room, room) {
+		return
+	}
+
+	saved := savedRoom{FetchedAt: fetchedAt, Room: room}
+	b, err := json.MarshalIndent(saved, "", "\t")
+	if err != nil {
+		log.L.Warnf("[config] unable to encode configuration for %s: %s", roomID, err)
+		return
+	}
+
+	if err := helpers.WriteFileAtomic(roomPath(roomID), b); err != nil {
+		log.L.Warnf("[config] unable to save configuration for %s: %s", roomID, err)
+		return
+	}
+
+	rooms.saved[roomID] = saved
+}
+
+func loadRoom(roomID string) (savedRoom, error) {
+	b, err := os.ReadFile(roomPath(roomID))
+	if err != nil {
+		return savedRoom{}, err
+	}
+
+	var saved savedRoom
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return savedRoom{}, fmt.Errorf("unable to parse saved configuration for %s: %w", roomID, err)
+	}
+
+	return saved, nil
+}
+
+func sameRoom(a structs.Room, b structs.Room) bool {
+	aj, aerr := json.Marshal(a)
+	bj, berr := json.Marshal(b)
+
+	return aerr == nil && berr == nil && bytes.Equal(aj, bj)
+}
+
+// StartRefresh fetches a room's configuration every interval so the saved copy stays current, until ctx is canceled.
+func StartRefresh(ctx context.Context, roomID string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		Invalidate(roomID)
+		if _, err := GetRoom(roomID); err != nil {
+			log.L.Warnf("[config] unable to refresh configuration for %s: %s", roomID, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/common/structs"
+)
+
+func stubDatabase(t *testing.T, room structs.Room, err *error) {
+	originalFetchRoom, originalFetchDevice := fetchRoom, fetchDevice
+	t.Cleanup(func() {
+		fetchRoom, fetchDevice = originalFetchRoom, originalFetchDevice
+	})
+
+	fetchRoom = func(roomID string) (structs.Room, error) {
+		if *err != nil {
+			return structs.Room{}, *err
+		}
+
+		return room, nil
+	}
+
+	fetchDevice = func(deviceID string) (structs.Device, error) {
+		if *err != nil {
+			return structs.Device{}, *err
+		}
+
+		for _, device := range room.Devices {
+			if device.ID == deviceID {
+				return device, nil
+			}
+		}
+
+		return structs.Device{}, errors.New("not found")
+	}
+}
+
+func TestGetRoomFallsBackToDiskOnRoomSystems(t *testing.T) {
+	t.Setenv("ROOM_SYSTEM", "true")
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	room := structs.Room{
+		ID:      "ITB-1101",
+		Devices: []structs.Device{{ID: "ITB-1101-D1", Name: "D1", Address: "10.0.0.1"}},
+	}
+
+	var dbErr error
+	stubDatabase(t, room, &dbErr)
+
+	if _, err := GetRoom("ITB-1101"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if info, _ := GetInfo("ITB-1101"); info.Source != SourceDatabase {
+		t.Fatalf("expected configuration from the database, got %+v", info)
+	}
+
+	dbErr = errors.New("connection refused")
+	Invalidate("ITB-1101")
+
+	cached, err := GetRoom("ITB-1101")
+	if err != nil {
+		t.Fatalf("expected the saved configuration, got error %s", err)
+	}
+	if len(cached.Devices) != 1 || cached.Devices[0].Address != "10.0.0.1" {
+		t.Fatalf("unexpected saved configuration: %+v", cached)
+	}
+	if info, _ := GetInfo("ITB-1101"); info.Source != SourceDisk {
+		t.Fatalf("expected configuration from disk, got %+v", info)
+	}
+
+	device, err := GetDevice("ITB-1101-D1")
+	if err != nil || device.Name != "D1" {
+		t.Fatalf("expected device from the saved configuration, got %+v, %v", device, err)
+	}
+
+	if _, err := GetRoom("ITB-1102"); err == nil {
+		t.Fatal("expected an error for a room that was never saved")
+	}
+}
+
+func TestGetRoomDoesNotSaveOffRoomSystems(t *testing.T) {
+	t.Setenv("ROOM_SYSTEM", "")
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	var dbErr error
+	stubDatabase(t, structs.Room{ID: "ITB-1103"}, &dbErr)
+
+	if _, err := GetRoom("ITB-1103"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	dbErr = errors.New("connection refused")
+	Invalidate("ITB-1103")
+	if _, err := GetRoom("ITB-1103"); err == nil {
+		t.Fatal("expected the database error when not a room system")
+	}
+}
+
+func TestGetRoomIsCachedUntilInvalidated(t *testing.T) {
+	t.Setenv("ROOM_SYSTEM", "")
+	SetCacheTTL(time.Minute)
+	defer SetCacheTTL(DefaultCacheTTL)
+
+	fetches := 0
+	originalFetchRoom := fetchRoom
+	defer func() {
+		fetchRoom = originalFetchRoom
+	}()
+
+	fetchRoom = func(roomID string) (structs.Room, error) {
+		fetches++
+		return structs.Room{
+			ID:      roomID,
+			Devices: []structs.Device{{ID: roomID + "-D1", Name: "D1"}},
+		}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := GetRoom("ITB-1104"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("expected 1 fetch while cached, got %d", fetches)
+	}
+
+	if device, err := GetDevice("ITB-1104-D1"); err != nil || device.Name != "D1" {
+		t.Fatalf("expected the device to be cached with its room, got %+v, %v", device, err)
+	}
+
+	Invalidate("ITB-1104")
+	if _, ok := cachedDeviceFor("ITB-1104-D1"); ok {
+		t.Fatal("expected invalidating the room to drop its devices")
+	}
+
+	if _, err := GetRoom("ITB-1104"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fetches != 2 {
+		t.Fatalf("expected a fetch after invalidating, got %d fetches", fetches)
+	}
+}
@@ -0,0 +1,255 @@
+// Package validate checks a room's configuration against what the api supports, so mistakes show up before the first request fails.
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/byuoitav/av-api/actionreconcilers"
+	ce "github.com/byuoitav/av-api/commandevaluators"
+	"github.com/byuoitav/av-api/config"
+	avapi "github.com/byuoitav/av-api/init"
+	"github.com/byuoitav/av-api/state"
+	se "github.com/byuoitav/av-api/statusevaluators"
+	"github.com/byuoitav/common/structs"
+)
+
+// Severities of a Problem. A room with errors won't work; warnings are likely mistakes.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// tieredSwitcherEvaluator is the evaluator that routes through switchers by INx/OUTx ports. Other switcher evaluators
+// expect each port to be an input:output route.
+const tieredSwitcherEvaluator = "ChangeVideoInputTieredSwitcher"
+
+var (
+	// getDevice looks up a device in any room, the way the mirror evaluators look up a mirror's destination.
+	getDevice = config.GetDevice
+
+	tieredPortRegex = regexp.MustCompile(`^(IN|OUT)\d+$`)
+	routePortRegex  = regexp.MustCompile(`^[^:]+:[^:]+$`)
+)
+
+// Problem is something wrong with a room's configuration.
+type Problem struct {
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	Subject  string `json:"subject"`
+	Message  string `json:"message"`
+}
+
+// Report is the result of validating a room's configuration.
+type Report struct {
+	Room     string    `json:"room"`
+	Valid    bool      `json:"valid"`
+	Problems []Problem `json:"problems"`
+}
+
+type checker struct {
+	room     structs.Room
+	devices  map[string]structs.Device
+	problems []Problem
+}
+
+func (c *checker) add(severity, check, subject, format string, a ...interface{}) {
+	c.problems = append(c.problems, Problem{
+		Severity: severity,
+		Check:    check,
+		Subject:  subject,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+// Room validates a room's configuration.
+func Room(room structs.Room) Report {
+	c := &checker{
+		room:    room,
+		devices: make(map[string]structs.Device),
+	}
+
+	for _, device := range room.Devices {
+		c.devices[device.ID] = device
+	}
+
+	c.checkEvaluators()
+	c.checkDescription()
+	c.checkPorts()
+	c.checkMirrors()
+	c.checkSwitchers()
+
+	report := Report{
+		Room:     room.ID,
+		Valid:    true,
+		Problems: c.problems,
+	}
+	if report.Problems == nil {
+		report.Problems = []Problem{}
+	}
+
+	for _, problem := range report.Problems {
+		if problem.Severity == SeverityError {
+			report.Valid = false
+		}
+	}
+
+	sort.SliceStable(report.Problems, func(i, j int) bool {
+		return report.Problems[i].Severity == SeverityError && report.Problems[j].Severity != SeverityError
+	})
+
+	return report
+}
+
+// checkEvaluators makes sure every evaluator exists, and that each command evaluator's results can be read back by a status evaluator in the room.
+func (c *checker) checkEvaluators() {
+	configured := make(map[string]bool)
+	for _, evaluator := range c.room.Configuration.Evaluators {
+		configured[evaluator.CodeKey] = true
+	}
+
+	for _, evaluator := range c.room.Configuration.Evaluators {
+		key := evaluator.CodeKey
+
+		if strings.HasPrefix(key, se.FLAG) {
+			if _, ok := se.StatusEvaluatorMap[key]; !ok {
+				c.add(SeverityError, "evaluator", key, "no status evaluator corresponding to key %s", key)
+			}
+			continue
+		}
+
+		if _, ok := ce.EVALUATORS[key]; !ok {
+			c.add(SeverityError, "evaluator", key, "no command evaluator corresponding to key %s", key)
+			continue
+		}
+
+		statusKey, ok := state.SET_STATE_STATUS_EVALUATORS[key]
+		if !ok {
+			c.add(SeverityWarning, "evaluator", key, "command evaluator %s has no status evaluator to report the state it sets", key)
+			continue
+		}
+
+		if _, ok := se.StatusEvaluatorMap[statusKey]; !ok {
+			c.add(SeverityError, "evaluator", key, "command evaluator %s reports its state with %s, which doesn't exist", key, statusKey)
+			continue
+		}
+
+		if !configured[statusKey] {
+			c.add(SeverityWarning, "evaluator", key, "command evaluator %s reports its state with %s, which isn't configured for the room", key, statusKey)
+		}
+	}
+}
+
+// checkDescription makes sure the room's configuration maps to a reconciler and an initializer.
+func (c *checker) checkDescription() {
+	description := c.room.Configuration.Description
+
+	if _, ok := actionreconcilers.Init()[description]; !ok {
+		c.add(SeverityError, "description", description, "no reconciler corresponding to configuration %q", description)
+	}
+
+	if len(description) > 0 && !avapi.HasInitializer(description) {
+		c.add(SeverityWarning, "description", description, "no initializer corresponding to configuration %q; room systems will fail to initialize", description)
+	}
+}
+
+// checkPorts makes sure every port references devices in the room. Mirror ports can point at a device in another room,
+// so they're left to checkMirrors.
+func (c *checker) checkPorts() {
+	for _, device := range c.room.Devices {
+		for _, port := range device.Ports {
+			if port.ID == "mirror" {
+				continue
+			}
+
+			subject := fmt.Sprintf("%s:%s", device.ID, port.ID)
+
+			if len(port.SourceDevice) > 0 {
+				if _, ok := c.devices[port.SourceDevice]; !ok {
+					c.add(SeverityError, "port", subject, "source device %s isn't in the room", port.SourceDevice)
+				}
+			}
+
+			if len(port.DestinationDevice) > 0 {
+				if _, ok := c.devices[port.DestinationDevice]; !ok {
+					c.add(SeverityError, "port", subject, "destination device %s isn't in the room", port.DestinationDevice)
+				}
+			}
+		}
+	}
+}
+
+// checkMirrors makes sure every MirrorMaster's mirror port points at a device that exists, in this room or another one.
+func (c *checker) checkMirrors() {
+	for _, device := range c.room.Devices {
+		if !structs.HasRole(device, "MirrorMaster") {
+			continue
+		}
+
+		found := false
+		for _, port := range device.Ports {
+			if port.ID != "mirror" {
+				continue
+			}
+
+			found = true
+			if len(port.DestinationDevice) == 0 {
+				c.add(SeverityError, "mirror", device.ID, "mirror port has no destination device")
+			} else if _, ok := c.devices[port.DestinationDevice]; !ok {
+				if _, err := getDevice(port.DestinationDevice); err != nil {
+					c.add(SeverityError, "mirror", device.ID, "unable to find mirror port's destination device %s: %s", port.DestinationDevice, err)
+				}
+			}
+		}
+
+		if !found {
+			c.add(SeverityWarning, "mirror", device.ID, "device has the MirrorMaster role but no mirror port")
+		}
+	}
+}
+
+// checkSwitchers makes sure video switchers' ports are named the way the room's switching evaluator sends them: INx or
+// OUTx for tiered switching, and input:output otherwise.
+func (c *checker) checkSwitchers() {
+	tiered := false
+	for _, evaluator := range c.room.Configuration.Evaluators {
+		if evaluator.CodeKey == tieredSwitcherEvaluator {
+			tiered = true
+		}
+	}
+
+	for _, device := range c.room.Devices {
+		if !structs.HasRole(device, "VideoSwitcher") {
+			continue
+		}
+
+		if !tiered {
+			for _, port := range device.Ports {
+				if !routePortRegex.MatchString(port.ID) {
+					c.add(SeverityError, "switcher", fmt.Sprintf("%s:%s", device.ID, port.ID), "video switcher port %q should be named input:output", port.ID)
+				}
+			}
+			continue
+		}
+
+		inputs, outputs := 0, 0
+		for _, port := range device.Ports {
+			if !tieredPortRegex.MatchString(port.ID) {
+				c.add(SeverityError, "switcher", fmt.Sprintf("%s:%s", device.ID, port.ID), "video switcher port %q should be named INx or OUTx", port.ID)
+				continue
+			}
+
+			if strings.HasPrefix(port.ID, "IN") {
+				inputs++
+			} else {
+				outputs++
+			}
+		}
+
+		if inputs == 0 || outputs == 0 {
+			c.add(SeverityWarning, "switcher", device.ID, "video switcher has %d input and %d output ports", inputs, outputs)
+		}
+	}
+}
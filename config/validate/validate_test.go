@@ -0,0 +1,161 @@
+package validate
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/byuoitav/common/structs"
+)
+
+// stubDevices makes the devices with ids the only ones that exist outside the room being validated.
+func stubDevices(t *testing.T, ids ...string) {
+	t.Helper()
+
+	original := getDevice
+	t.Cleanup(func() {
+		getDevice = original
+	})
+
+	getDevice = func(id string) (structs.Device, error) {
+		for _, known := range ids {
+			if id == known {
+				return structs.Device{ID: id}, nil
+			}
+		}
+
+		return structs.Device{}, errors.New("device not found")
+	}
+}
+
+func problemsFor(report Report, check string) []Problem {
+	var problems []Problem
+	for _, problem := range report.Problems {
+		if problem.Check == check {
+			problems = append(problems, problem)
+		}
+	}
+
+	return problems
+}
+
+func TestRoomWithValidConfiguration(t *testing.T) {
+	room := structs.Room{
+		ID: "ITB-1101",
+		Configuration: structs.RoomConfiguration{
+			Description: "Default",
+			Evaluators: []structs.Evaluator{
+				{CodeKey: "PowerOnDefault"},
+				{CodeKey: "StandbyDefault"},
+				{CodeKey: "STATUS_PowerDefault"},
+			},
+		},
+		Devices: []structs.Device{
+			{ID: "ITB-1101-D1", Roles: []structs.Role{{ID: "VideoOut"}}},
+			{ID: "ITB-1101-SW1", Roles: []structs.Role{{ID: "VideoSwitcher"}}, Ports: []structs.Port{
+				{ID: "1:1", SourceDevice: "ITB-1101-HDMI1", DestinationDevice: "ITB-1101-D1"},
+			}},
+			{ID: "ITB-1101-HDMI1"},
+		},
+	}
+
+	report := Room(room)
+	if !report.Valid || len(report.Problems) != 0 {
+		t.Fatalf("expected a valid room, got %+v", report)
+	}
+}
+
+func TestRoomWithInvalidConfiguration(t *testing.T) {
+	stubDevices(t)
+
+	room := structs.Room{
+		ID: "ITB-1101",
+		Configuration: structs.RoomConfiguration{
+			Description: "Unknown",
+			Evaluators: []structs.Evaluator{
+				{CodeKey: "PowerOnDefualt"},
+				{CodeKey: "STATUS_Nope"},
+				{CodeKey: "StandbyDefault"},
+			},
+		},
+		Devices: []structs.Device{
+			{ID: "ITB-1101-D1", Roles: []structs.Role{{ID: "MirrorMaster"}}, Ports: []structs.Port{
+				{ID: "mirror", DestinationDevice: "ITB-1101-D9"},
+			}},
+			{ID: "ITB-1101-SW1", Roles: []structs.Role{{ID: "VideoSwitcher"}}, Ports: []structs.Port{
+				{ID: "IN1", SourceDevice: "ITB-1101-HDMI1", DestinationDevice: "ITB-1101-SW1"},
+			}},
+		},
+	}
+
+	report := Room(room)
+	if report.Valid {
+		t.Fatal("expected an invalid room")
+	}
+
+	if got := len(problemsFor(report, "evaluator")); got != 3 {
+		t.Fatalf("expected 3 evaluator problems (2 unknown keys, 1 unconfigured status evaluator), got %+v", problemsFor(report, "evaluator"))
+	}
+	if got := len(problemsFor(report, "description")); got != 2 {
+		t.Fatalf("expected missing reconciler and initializer, got %+v", problemsFor(report, "description"))
+	}
+	if got := len(problemsFor(report, "mirror")); got != 1 {
+		t.Fatalf("expected an unresolved mirror port, got %+v", problemsFor(report, "mirror"))
+	}
+	if got := len(problemsFor(report, "switcher")); got != 1 {
+		t.Fatalf("expected a bad switcher port, got %+v", problemsFor(report, "switcher"))
+	}
+	if got := len(problemsFor(report, "port")); got != 1 {
+		t.Fatalf("expected the switcher port referencing a missing device, got %+v", problemsFor(report, "port"))
+	}
+
+	if report.Problems[0].Severity != SeverityError {
+		t.Fatalf("expected errors to be listed first, got %+v", report.Problems)
+	}
+}
+
+func TestRoomWithMirrorInAnotherRoom(t *testing.T) {
+	stubDevices(t, "ITB-1102-D1")
+
+	room := structs.Room{
+		ID: "ITB-1101",
+		Configuration: structs.RoomConfiguration{
+			Description: "Default",
+			Evaluators:  []structs.Evaluator{{CodeKey: "PowerOnDefault"}},
+		},
+		Devices: []structs.Device{
+			{ID: "ITB-1101-D1", Roles: []structs.Role{{ID: "MirrorMaster"}}, Ports: []structs.Port{
+				{ID: "mirror", DestinationDevice: "ITB-1102-D1"},
+			}},
+		},
+	}
+
+	report := Room(room)
+	if problems := append(problemsFor(report, "mirror"), problemsFor(report, "port")...); len(problems) != 0 {
+		t.Fatalf("expected a mirror to another room's device to be valid, got %+v", problems)
+	}
+}
+
+func TestRoomWithTieredSwitchers(t *testing.T) {
+	room := structs.Room{
+		ID: "ITB-1101",
+		Configuration: structs.RoomConfiguration{
+			Description: "Default",
+			Evaluators: []structs.Evaluator{
+				{CodeKey: "ChangeVideoInputTieredSwitcher"},
+				{CodeKey: "STATUS_InputVideoSwitcher"},
+			},
+		},
+		Devices: []structs.Device{
+			{ID: "ITB-1101-SW1", Roles: []structs.Role{{ID: "VideoSwitcher"}}, Ports: []structs.Port{
+				{ID: "IN1", SourceDevice: "ITB-1101-HDMI1", DestinationDevice: "ITB-1101-SW1"},
+				{ID: "1:1", SourceDevice: "ITB-1101-HDMI1", DestinationDevice: "ITB-1101-SW1"},
+			}},
+			{ID: "ITB-1101-HDMI1"},
+		},
+	}
+
+	report := Room(room)
+	if got := len(problemsFor(report, "switcher")); got != 2 {
+		t.Fatalf("expected a bad switcher port and missing outputs, got %+v", problemsFor(report, "switcher"))
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/byuoitav/av-api/helpers"
+	"github.com/byuoitav/av-api/state"
+	"github.com/labstack/echo"
+)
+
+// GetRoomStateAudit returns the room state changes recorded in a room's audit log, oldest first. ?since= is an RFC 3339
+// time or a duration back from now, like 2h, and ?requestor= selects the changes one requestor made
+func GetRoomStateAudit(ctx echo.Context) error {
+	filter := state.AuditFilter{
+		Requestor: ctx.QueryParam("requestor"),
+	}
+
+	if since := ctx.QueryParam("since"); len(since) > 0 {
+		t, err := parseSince(since)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, helpers.ReturnError(err))
+		}
+
+		filter.Since = t
+	}
+
+	records, err := state.GetAuditRecords(ctx.Param("building"), ctx.Param("room"), filter)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, helpers.ReturnError(err))
+	}
+
+	return ctx.JSON(http.StatusOK, records)
+}
+
+func parseSince(since string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+
+	d, err := time.ParseDuration(since)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid since %q; expected an RFC 3339 time or a duration like 2h", since)
+	}
+
+	return time.Now().Add(-d), nil
+}
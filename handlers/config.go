@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/byuoitav/av-api/config"
+	"github.com/byuoitav/av-api/config/validate"
+	"github.com/byuoitav/av-api/helpers"
+	"github.com/byuoitav/common/log"
+	"github.com/labstack/echo"
+)
+
+// Headers reporting where the room configuration used for a request came from
+const (
+	HeaderConfigSource = "X-Config-Source"
+	HeaderConfigAge    = "X-Config-Age"
+)
+
+// ConfigHeaders adds the source and age, in seconds, of the room's configuration to responses for room requests
+func ConfigHeaders(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		if len(ctx.Param("building")) == 0 || len(ctx.Param("room")) == 0 {
+			return next(ctx)
+		}
+
+		roomID := GetRoomResource(ctx)
+		ctx.Response().Before(func() {
+			info, ok := config.GetInfo(roomID)
+			if !ok {
+				return
+			}
+
+			ctx.Response().Header().Set(HeaderConfigSource, info.Source)
+			ctx.Response().Header().Set(HeaderConfigAge, strconv.Itoa(int(info.Age().Seconds())))
+		})
+
+		return next(ctx)
+	}
+}
+
+// RefreshRoomConfiguration drops a room's cached configuration and fetches it again
+func RefreshRoomConfiguration(ctx echo.Context) error {
+	roomID := GetRoomResource(ctx)
+	config.Invalidate(roomID)
+
+	if _, err := config.GetRoom(roomID); err != nil {
+		log.L.Errorf("[handlers] unable to refresh configuration for %s: %s", roomID, err)
+		return ctx.JSON(http.StatusServiceUnavailable, helpers.ReturnError(err))
+	}
+
+	info, _ := config.GetInfo(roomID)
+	return ctx.JSON(http.StatusOK, info)
+}
+
+// ValidateRoomConfiguration checks a room's configuration against what the api supports
+func ValidateRoomConfiguration(ctx echo.Context) error {
+	roomID := GetRoomResource(ctx)
+
+	room, err := config.GetRoom(roomID)
+	if err != nil {
+		log.L.Errorf("[handlers] unable to get configuration for %s: %s", roomID, err)
+		return ctx.JSON(http.StatusServiceUnavailable, helpers.ReturnError(err))
+	}
+
+	return ctx.JSON(http.StatusOK, validate.Room(room))
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/helpers"
+	"github.com/byuoitav/av-api/state"
+	"github.com/byuoitav/common/log"
+	"github.com/fatih/color"
+	"github.com/labstack/echo"
+)
+
+// GetDeviceState returns the state of one device in a room, without querying the room's other devices
+func GetDeviceState(ctx echo.Context) error {
+	building, room, device := ctx.Param("building"), ctx.Param("room"), ctx.Param("device")
+
+	requestContext, cancel := context2WithTimeout(ctx.Request().Context(), roomStateTimeout)
+	defer cancel()
+
+	status, err := state.GetDeviceStateWithContext(requestContext, building, room, device)
+	if err != nil {
+		log.L.Errorf("[handlers] unable to get state of %s-%s-%s: %s", building, room, device, err.Error())
+		return ctx.JSON(http.StatusBadRequest, helpers.ReturnError(err))
+	}
+
+	return ctx.JSON(http.StatusOK, status)
+}
+
+// SetDeviceState changes the state of one device in a room and returns the same report a room state change does. It's queued
+// with the room's other state changes, and accepts the same ?verify, ?trace, and ?superseded flags
+func SetDeviceState(ctx echo.Context) error {
+	building, room, device := ctx.Param("building"), ctx.Param("room"), ctx.Param("device")
+
+	log.L.Infof("%s", color.HiGreenString("[handlers] putting device changes..."))
+
+	var target base.DeviceState
+	if err := ctx.Bind(&target); err != nil {
+		return ctx.JSON(http.StatusBadRequest, helpers.ReturnError(err))
+	}
+
+	roomInQuestion, err := state.DeviceTarget(building, room, device, target)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, helpers.ReturnError(err))
+	}
+
+	requestContext, cancelRequest := context2WithTimeout(ctx.Request().Context(), roomStateTimeout)
+	defer cancelRequest()
+
+	requestContext = state.WithSetRoomStateOptions(requestContext, state.SetRoomStateOptions{
+		Verify:       ctx.QueryParam("verify") == "true",
+		Trace:        ctx.QueryParam("trace") == "true",
+		MergedReport: ctx.QueryParam("superseded") == "report",
+	})
+
+	report, err := state.SetRoomStateLatest(requestContext, roomInQuestion, getRequestor(ctx))
+	if err != nil {
+		log.L.Errorf("Error: %s", err.Error())
+		if errors.Is(err, state.ErrSuperseded) {
+			return ctx.JSON(http.StatusConflict, helpers.ReturnError(err))
+		}
+
+		return ctx.JSON(http.StatusInternalServerError, helpers.ReturnError(err))
+	}
+
+	return ctx.JSON(http.StatusOK, report)
+}
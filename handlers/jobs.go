@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/byuoitav/av-api/helpers"
+	"github.com/byuoitav/av-api/state"
+	"github.com/labstack/echo"
+)
+
+// RequireRoomStateJob responds with a 404 to a request about a room state job that doesn't exist or has expired, before
+// the request is authorized against the room the job changes
+func RequireRoomStateJob(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		if _, err := state.GetRoomStateJob(ctx.Param("id")); err != nil {
+			return ctx.JSON(http.StatusNotFound, helpers.ReturnError(err))
+		}
+
+		return next(ctx)
+	}
+}
+
+// GetJobResource returns the resourceID for a request about a room state job, which is the room the job changes
+func GetJobResource(ctx echo.Context) string {
+	job, err := state.GetRoomStateJob(ctx.Param("id"))
+	if err != nil {
+		return ""
+	}
+
+	return job.Building + "-" + job.Room
+}
+
+// GetRoomStateJob returns the status of a room state change submitted with ?async=true
+func GetRoomStateJob(ctx echo.Context) error {
+	job, err := state.GetRoomStateJob(ctx.Param("id"))
+	if err != nil {
+		return ctx.JSON(http.StatusNotFound, helpers.ReturnError(err))
+	}
+
+	return ctx.JSON(http.StatusOK, job)
+}
+
+// CancelRoomStateJob cancels a queued or running room state change. A change merged into the running one can no longer be cancelled
+func CancelRoomStateJob(ctx echo.Context) error {
+	job, err := state.CancelRoomStateJob(ctx.Param("id"))
+	switch {
+	case errors.Is(err, state.ErrJobNotFound):
+		return ctx.JSON(http.StatusNotFound, helpers.ReturnError(err))
+	case errors.Is(err, state.ErrJobFinished), errors.Is(err, state.ErrJobMerged):
+		return ctx.JSON(http.StatusConflict, helpers.ReturnError(err))
+	case err != nil:
+		return ctx.JSON(http.StatusInternalServerError, helpers.ReturnError(err))
+	}
+
+	return ctx.JSON(http.StatusOK, job)
+}
+
+// GetRoomStateQueue lists the room state change a room is running and the ones queued behind it
+func GetRoomStateQueue(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, state.GetRoomStateQueue(ctx.Param("building"), ctx.Param("room")))
+}
+
+// CancelQueuedRoomState cancels a queued room state change, or the one the room is running. Whoever is waiting on it gets a 409
+func CancelQueuedRoomState(ctx echo.Context) error {
+	job, err := state.CancelQueuedRoomState(ctx.Param("building"), ctx.Param("room"), ctx.Param("id"))
+	switch {
+	case errors.Is(err, state.ErrJobNotFound):
+		return ctx.JSON(http.StatusNotFound, helpers.ReturnError(err))
+	case errors.Is(err, state.ErrJobFinished), errors.Is(err, state.ErrJobMerged):
+		return ctx.JSON(http.StatusConflict, helpers.ReturnError(err))
+	case err != nil:
+		return ctx.JSON(http.StatusInternalServerError, helpers.ReturnError(err))
+	}
+
+	return ctx.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func TestExpiredRoomStateJobIsNotFoundBeforeAuthorization(t *testing.T) {
+	authorize := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			t.Fatalf("expected %s %s not to be authorized", ctx.Request().Method, ctx.Path())
+			return nil
+		}
+	}
+
+	router := echo.New()
+	router.GET("/jobs/:id", GetRoomStateJob, RequireRoomStateJob, authorize)
+	router.DELETE("/jobs/:id", CancelRoomStateJob, RequireRoomStateJob, authorize)
+
+	// a job is forgotten once it expires, so its id looks like one that never existed
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(method, "/jobs/expired", nil))
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected %s of an expired job to be a 404, got %d: %s", method, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/byuoitav/av-api/metrics"
+	"github.com/labstack/echo"
+)
+
+// GetMetrics writes the api's metrics in the Prometheus text exposition format
+func GetMetrics(ctx echo.Context) error {
+	ctx.Response().Header().Set(echo.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	ctx.Response().WriteHeader(http.StatusOK)
+
+	return metrics.Write(ctx.Response())
+}
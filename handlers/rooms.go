@@ -11,9 +11,9 @@ import (
 	"time"
 
 	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/av-api/helpers"
 	"github.com/byuoitav/av-api/state"
-	"github.com/byuoitav/common/db"
 	"github.com/byuoitav/common/inputgraph"
 	"github.com/byuoitav/common/log"
 	"github.com/fatih/color"
@@ -42,10 +42,22 @@ func GetRoomResource(context echo.Context) string {
 	return context.Param("building") + "-" + context.Param("room")
 }
 
-// GetRoomState to get the current state of a room
+// GetRoomState to get the current state of a room. ?fields=power,input only reads those fields, and ?devices=D1,D2 only reads those devices.
+// On room systems, unfiltered reads are served from the room's state snapshot.
 func GetRoomState(context echo.Context) error {
 	building, room := context.Param("building"), context.Param("room")
 
+	filter, err := state.ParseStatusFilter(context.QueryParam("fields"), context.QueryParam("devices"))
+	if err != nil {
+		return context.JSON(http.StatusBadRequest, helpers.ReturnError(err))
+	}
+
+	if len(filter.String()) == 0 {
+		if snapshot, ok := state.GetRoomSnapshot(building, room); ok {
+			return context.JSON(http.StatusOK, snapshot)
+		}
+	}
+
 	requestContext, cancel := context2WithTimeout(context.Request().Context(), roomStateTimeout)
 	defer cancel()
 
@@ -53,7 +65,7 @@ func GetRoomState(context echo.Context) error {
 	go func() {
 		defer recoverRoomState(resultChan)
 
-		status, err := state.GetRoomStateShared(requestContext, building, room, roomStateCacheTTL, roomStateTimeout)
+		status, err := state.GetRoomStateSharedWithFilter(requestContext, building, room, filter, roomStateCacheTTL, roomStateTimeout)
 		resultChan <- roomStateResult{status: status, err: err}
 	}()
 
@@ -83,7 +95,7 @@ func GetRoomByNameAndBuilding(context echo.Context) error {
 		defer recoverRoomConfig(resultChan)
 
 		log.L.Info("Getting room...")
-		room, err := db.GetDB().GetRoom(fmt.Sprintf("%s-%s", building, roomName))
+		room, err := config.GetRoom(fmt.Sprintf("%s-%s", building, roomName))
 		if err != nil {
 			resultChan <- roomConfigResult{err: err}
 			return
@@ -133,7 +145,10 @@ func recoverRoomConfig(resultChan chan<- roomConfigResult) {
 	}
 }
 
-// SetRoomState to update the state of the room
+// SetRoomState to update the state of the room. With ?verify=true, the report lists the requested fields devices didn't report back;
+// with ?trace=true, it includes a timeline of the request. With ?async=true, it returns 202 and a job to follow at /jobs/:id instead
+// of waiting for the change. Changes that queue up behind a running one are merged, and all but the newest get a 409; with
+// ?superseded=report, they get the merged report instead.
 func SetRoomState(ctx echo.Context) error {
 	building, room := ctx.Param("building"), ctx.Param("room")
 
@@ -147,31 +162,23 @@ func SetRoomState(ctx echo.Context) error {
 
 	roomInQuestion.Room = room
 	roomInQuestion.Building = building
-	var report base.PublicRoom
 	requestContext, cancelRequest := context2WithTimeout(ctx.Request().Context(), roomStateTimeout)
 	defer cancelRequest()
 
-	gctx, cancel := context.WithTimeout(context.TODO(), timeout)
-	defer cancel()
+	requestContext = state.WithSetRoomStateOptions(requestContext, state.SetRoomStateOptions{
+		Verify:       ctx.QueryParam("verify") == "true",
+		Trace:        ctx.QueryParam("trace") == "true",
+		MergedReport: ctx.QueryParam("superseded") == "report",
+	})
 
-	r := net.Resolver{}
-	hn, err := r.LookupAddr(gctx, ctx.RealIP())
+	if ctx.QueryParam("async") == "true" {
+		job := state.SubmitRoomState(requestContext, roomInQuestion, getRequestor(ctx))
 
-	color.Set(color.FgYellow, color.Bold)
-	if err != nil || len(hn) == 0 {
-		log.L.Debugf("REQUESTOR: %s", ctx.RealIP())
-		color.Unset()
-		report, err = state.SetRoomStateLatest(requestContext, roomInQuestion, ctx.RealIP())
-	} else if strings.Contains(hn[0], "localhost") {
-		log.L.Debugf("REQUESTOR: %s", os.Getenv("SYSTEM_ID"))
-		color.Unset()
-		report, err = state.SetRoomStateLatest(requestContext, roomInQuestion, os.Getenv("SYSTEM_ID"))
-	} else {
-		log.L.Debugf("REQUESTOR: %s", hn[0])
-		color.Unset()
-		report, err = state.SetRoomStateLatest(requestContext, roomInQuestion, hn[0])
+		ctx.Response().Header().Set(echo.HeaderLocation, "/jobs/"+job.ID)
+		return ctx.JSON(http.StatusAccepted, job)
 	}
 
+	report, err := state.SetRoomStateLatest(requestContext, roomInQuestion, getRequestor(ctx))
 	if err != nil {
 		log.L.Errorf("Error: %s", err.Error())
 		if errors.Is(err, state.ErrSuperseded) {
@@ -185,3 +192,48 @@ func SetRoomState(ctx echo.Context) error {
 
 	return ctx.JSON(http.StatusOK, report)
 }
+
+// PlanRoomState returns the reconciled actions a room state change would execute, without executing them
+func PlanRoomState(ctx echo.Context) error {
+	building, room := ctx.Param("building"), ctx.Param("room")
+
+	var roomInQuestion base.PublicRoom
+	err := ctx.Bind(&roomInQuestion)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, helpers.ReturnError(err))
+	}
+
+	roomInQuestion.Room = room
+	roomInQuestion.Building = building
+
+	plan, err := state.PlanRoomState(roomInQuestion, getRequestor(ctx))
+	if err != nil {
+		log.L.Errorf("[handlers] unable to plan room state for %s-%s: %s", building, room, err.Error())
+		return ctx.JSON(http.StatusBadRequest, helpers.ReturnError(err))
+	}
+
+	return ctx.JSON(http.StatusOK, plan)
+}
+
+// getRequestor resolves the hostname of the client making the request, falling back to its IP address
+func getRequestor(ctx echo.Context) string {
+	gctx, cancel := context.WithTimeout(context.TODO(), timeout)
+	defer cancel()
+
+	r := net.Resolver{}
+	hn, err := r.LookupAddr(gctx, ctx.RealIP())
+
+	color.Set(color.FgYellow, color.Bold)
+	defer color.Unset()
+
+	if err != nil || len(hn) == 0 {
+		log.L.Debugf("REQUESTOR: %s", ctx.RealIP())
+		return ctx.RealIP()
+	} else if strings.Contains(hn[0], "localhost") {
+		log.L.Debugf("REQUESTOR: %s", os.Getenv("SYSTEM_ID"))
+		return os.Getenv("SYSTEM_ID")
+	}
+
+	log.L.Debugf("REQUESTOR: %s", hn[0])
+	return hn[0]
+}
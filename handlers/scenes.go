@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/byuoitav/av-api/config"
+	"github.com/byuoitav/av-api/helpers"
+	"github.com/byuoitav/av-api/scenes"
+	"github.com/byuoitav/av-api/state"
+	"github.com/byuoitav/common/log"
+	"github.com/labstack/echo"
+)
+
+// GetScenes returns every scene saved for a room
+func GetScenes(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, scenes.GetStore().List(GetRoomResource(ctx)))
+}
+
+// GetScene returns a single scene
+func GetScene(ctx echo.Context) error {
+	scene, err := scenes.GetStore().Get(GetRoomResource(ctx), ctx.Param("name"))
+	if err != nil {
+		return ctx.JSON(sceneErrorStatus(err), helpers.ReturnError(err))
+	}
+
+	return ctx.JSON(http.StatusOK, scene)
+}
+
+// SetScene validates a scene against the room's devices and saves it
+func SetScene(ctx echo.Context) error {
+	roomID := GetRoomResource(ctx)
+
+	var scene scenes.Scene
+	err := ctx.Bind(&scene.Room)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, helpers.ReturnError(err))
+	}
+	scene.Name = ctx.Param("name")
+
+	room, err := config.GetRoom(roomID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, helpers.ReturnError(fmt.Errorf("unable to get room %s: %w", roomID, err)))
+	}
+
+	if err := scenes.Validate(room, scene); err != nil {
+		return ctx.JSON(http.StatusBadRequest, helpers.ReturnError(err))
+	}
+
+	if err := scenes.GetStore().Put(roomID, scene); err != nil {
+		log.L.Errorf("[handlers] unable to save scene %s for %s: %s", scene.Name, roomID, err)
+		return ctx.JSON(http.StatusInternalServerError, helpers.ReturnError(err))
+	}
+
+	return ctx.JSON(http.StatusOK, scene)
+}
+
+// DeleteScene removes a scene from a room
+func DeleteScene(ctx echo.Context) error {
+	err := scenes.GetStore().Delete(GetRoomResource(ctx), ctx.Param("name"))
+	if err != nil {
+		return ctx.JSON(sceneErrorStatus(err), helpers.ReturnError(err))
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// ApplyScene sets the room to the state saved in a scene
+func ApplyScene(ctx echo.Context) error {
+	building, room := ctx.Param("building"), ctx.Param("room")
+
+	scene, err := scenes.GetStore().Get(GetRoomResource(ctx), ctx.Param("name"))
+	if err != nil {
+		return ctx.JSON(sceneErrorStatus(err), helpers.ReturnError(err))
+	}
+
+	log.L.Infof("[handlers] applying scene %s to %s-%s", scene.Name, building, room)
+
+	target := scene.Room
+	target.Building = building
+	target.Room = room
+
+	requestContext, cancel := context2WithTimeout(ctx.Request().Context(), roomStateTimeout)
+	defer cancel()
+
+	report, err := state.SetRoomStateLatest(requestContext, target, getRequestor(ctx))
+	if err != nil {
+		log.L.Errorf("[handlers] unable to apply scene %s to %s-%s: %s", scene.Name, building, room, err)
+		if errors.Is(err, state.ErrSuperseded) {
+			return ctx.JSON(http.StatusConflict, helpers.ReturnError(err))
+		}
+
+		return ctx.JSON(http.StatusInternalServerError, helpers.ReturnError(err))
+	}
+
+	return ctx.JSON(http.StatusOK, report)
+}
+
+func sceneErrorStatus(err error) int {
+	if errors.Is(err, scenes.ErrNotFound) {
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
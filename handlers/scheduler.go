@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/byuoitav/av-api/helpers"
+	"github.com/byuoitav/av-api/scheduler"
+	"github.com/labstack/echo"
+)
+
+// GetScheduledJobs returns the scheduled jobs for a room
+func GetScheduledJobs(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, scheduler.GetScheduler().List(GetRoomResource(ctx)))
+}
+
+// AddScheduledJob creates a new scheduled job for a room
+func AddScheduledJob(ctx echo.Context) error {
+	var job scheduler.Job
+	err := ctx.Bind(&job)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, helpers.ReturnError(err))
+	}
+
+	job.Building = ctx.Param("building")
+	job.Room = ctx.Param("room")
+
+	job, err = scheduler.GetScheduler().Add(job)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, helpers.ReturnError(err))
+	}
+
+	return ctx.JSON(http.StatusCreated, job)
+}
+
+// DeleteScheduledJob removes a scheduled job from a room
+func DeleteScheduledJob(ctx echo.Context) error {
+	err := scheduler.GetScheduler().Delete(GetRoomResource(ctx), ctx.Param("id"))
+	switch {
+	case errors.Is(err, scheduler.ErrNotFound):
+		return ctx.JSON(http.StatusNotFound, helpers.ReturnError(err))
+	case err != nil:
+		return ctx.JSON(http.StatusInternalServerError, helpers.ReturnError(err))
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/byuoitav/av-api/state"
+	"github.com/byuoitav/common/log"
+	"github.com/labstack/echo"
+)
+
+const subscriptionKeepAlive = 30 * time.Second
+
+// SubscribeRoomState streams changes to the state of a room as server-sent events
+func SubscribeRoomState(ctx echo.Context) error {
+	building, room := ctx.Param("building"), ctx.Param("room")
+
+	updates, unsubscribe := state.SubscribeRoomState(building, room)
+	defer unsubscribe()
+
+	log.L.Infof("[handlers] %s subscribed to room state for %s-%s", ctx.RealIP(), building, room)
+
+	response := ctx.Response()
+	response.Header().Set(echo.HeaderContentType, "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Header().Set("Connection", "keep-alive")
+	response.WriteHeader(http.StatusOK)
+	response.Flush()
+
+	keepAlive := time.NewTicker(subscriptionKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Request().Context().Done():
+			log.L.Infof("[handlers] %s unsubscribed from room state for %s-%s", ctx.RealIP(), building, room)
+			return nil
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(response, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			response.Flush()
+		case update := <-updates:
+			b, err := json.Marshal(update)
+			if err != nil {
+				log.L.Errorf("[handlers] unable to marshal room state update for %s-%s: %s", building, room, err)
+				continue
+			}
+
+			if _, err := fmt.Fprintf(response, "event: %s\ndata: %s\n\n", update.Source, b); err != nil {
+				return nil
+			}
+			response.Flush()
+		}
+	}
+}
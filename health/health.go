@@ -2,8 +2,11 @@ package health
 
 import (
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/common/db"
 	"github.com/byuoitav/common/health"
 	"github.com/byuoitav/common/log"
@@ -31,6 +34,14 @@ func GetHealth() map[string]string {
 		healthReport["Configuration Database Microservice Connectivity"] = "ok"
 	}
 
+	if len(os.Getenv("ROOM_SYSTEM")) > 0 {
+		healthReport["Configuration Source"] = "unknown"
+		if info, ok := config.GetInfo(config.SystemRoomID()); ok {
+			healthReport["Configuration Source"] = info.Source
+			healthReport["Configuration Age"] = info.Age().Round(time.Second).String()
+		}
+	}
+
 	log.L.Info("[HealthCheck] Done. Report:")
 	for k, v := range healthReport {
 		log.L.Infof("%v: %v", k, v)
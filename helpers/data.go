@@ -0,0 +1,31 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DataPath returns the path to a file in the directory where local data is kept, set by DATA_DIR.
+// If DATA_DIR is not set, the working directory is used.
+func DataPath(name string) string {
+	dir := os.Getenv("DATA_DIR")
+	if len(dir) == 0 {
+		dir = "."
+	}
+
+	return filepath.Join(dir, name)
+}
+
+// WriteFileAtomic writes data to a temporary file and renames it over path, so readers never see a partial file.
+func WriteFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
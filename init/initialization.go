@@ -7,7 +7,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/byuoitav/common/db"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/common/log"
 	"github.com/byuoitav/common/structs"
 )
@@ -47,14 +47,14 @@ func CheckRoomInitialization() error {
 
 	attempts := 0
 
-	room, err := db.GetDB().GetRoom(roomID)
+	room, err := config.GetRoom(roomID)
 	if err != nil {
 
 		//If there was an error we want to attempt to connect multiple times - as the
 		//configuration service may not be up.
 		for attempts < 40 {
 			log.L.Info("[init] Attempting to connect to DB...")
-			room, err = db.GetDB().GetRoom(roomID)
+			room, err = config.GetRoom(roomID)
 			if err != nil {
 				log.L.Errorf("[init] Error: %s", err.Error())
 				attempts++
@@ -109,3 +109,9 @@ func getMap() map[string]RoomInitializer {
 
 	return InitializerMap
 }
+
+//HasInitializer reports whether a room configuration description has an initializer
+func HasInitializer(description string) bool {
+	_, ok := getMap()[description]
+	return ok
+}
@@ -0,0 +1,182 @@
+package testdevice
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/byuoitav/common/db"
+	"github.com/byuoitav/common/structs"
+)
+
+// DB is an in-memory stand-in for the configuration database that holds room fixtures. It implements the room,
+// device, and building lookups the API uses; any other method of db.DB panics.
+type DB struct {
+	db.DB
+
+	mu    sync.RWMutex
+	rooms map[string]structs.Room
+}
+
+// NewDB returns a DB holding rooms.
+func NewDB(rooms ...structs.Room) *DB {
+	d := &DB{
+		rooms: make(map[string]structs.Room),
+	}
+
+	for _, room := range rooms {
+		d.rooms[room.ID] = room
+	}
+
+	return d
+}
+
+// CreateRoom adds a room.
+func (d *DB) CreateRoom(room structs.Room) (structs.Room, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.rooms[room.ID]; ok {
+		return structs.Room{}, fmt.Errorf("room %s already exists", room.ID)
+	}
+
+	d.rooms[room.ID] = room
+	return room, nil
+}
+
+// GetRoom gets a room.
+func (d *DB) GetRoom(id string) (structs.Room, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	room, ok := d.rooms[id]
+	if !ok {
+		return structs.Room{}, fmt.Errorf("room %s not found", id)
+	}
+
+	return room, nil
+}
+
+// UpdateRoom replaces a room.
+func (d *DB) UpdateRoom(id string, room structs.Room) (structs.Room, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.rooms[id]; !ok {
+		return structs.Room{}, fmt.Errorf("room %s not found", id)
+	}
+
+	delete(d.rooms, id)
+	d.rooms[room.ID] = room
+	return room, nil
+}
+
+// DeleteRoom removes a room.
+func (d *DB) DeleteRoom(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.rooms[id]; !ok {
+		return fmt.Errorf("room %s not found", id)
+	}
+
+	delete(d.rooms, id)
+	return nil
+}
+
+// GetAllRooms gets every room, sorted by ID.
+func (d *DB) GetAllRooms() ([]structs.Room, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	rooms := []structs.Room{}
+	for _, room := range d.rooms {
+		rooms = append(rooms, room)
+	}
+
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].ID < rooms[j].ID
+	})
+
+	return rooms, nil
+}
+
+// GetRoomsByBuilding gets every room in a building.
+func (d *DB) GetRoomsByBuilding(id string) ([]structs.Room, error) {
+	all, _ := d.GetAllRooms()
+
+	rooms := []structs.Room{}
+	for _, room := range all {
+		if strings.HasPrefix(room.ID, id+"-") {
+			rooms = append(rooms, room)
+		}
+	}
+
+	return rooms, nil
+}
+
+// GetAllBuildings gets every building that has a room.
+func (d *DB) GetAllBuildings() ([]structs.Building, error) {
+	all, _ := d.GetAllRooms()
+
+	buildings := []structs.Building{}
+	seen := make(map[string]bool)
+	for _, room := range all {
+		id := strings.Split(room.ID, "-")[0]
+		if !seen[id] {
+			seen[id] = true
+			buildings = append(buildings, structs.Building{ID: id})
+		}
+	}
+
+	return buildings, nil
+}
+
+// GetDevice gets a device from the room it's in.
+func (d *DB) GetDevice(id string) (structs.Device, error) {
+	room, err := d.GetRoom((&structs.Device{ID: id}).GetDeviceRoomID())
+	if err != nil {
+		return structs.Device{}, fmt.Errorf("device %s not found", id)
+	}
+
+	for _, device := range room.Devices {
+		if device.ID == id {
+			return device, nil
+		}
+	}
+
+	return structs.Device{}, fmt.Errorf("device %s not found", id)
+}
+
+// GetDevicesByRoom gets the devices in a room.
+func (d *DB) GetDevicesByRoom(roomID string) ([]structs.Device, error) {
+	room, err := d.GetRoom(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	return room.Devices, nil
+}
+
+// GetDevicesByRoomAndRole gets the devices in a room with a role.
+func (d *DB) GetDevicesByRoomAndRole(roomID, roleID string) ([]structs.Device, error) {
+	devices, err := d.GetDevicesByRoom(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []structs.Device
+	for _, device := range devices {
+		if structs.HasRole(device, roleID) {
+			matches = append(matches, device)
+		}
+	}
+
+	return matches, nil
+}
+
+// GetStatus reports the database as ready.
+func (d *DB) GetStatus() (string, error) {
+	return "ok", nil
+}
@@ -0,0 +1,159 @@
+package testdevice
+
+import (
+	"strings"
+
+	"github.com/byuoitav/common/structs"
+)
+
+func (s *Server) newDevice(id string, roles []string, endpoints Endpoints, ports ...structs.Port) structs.Device {
+	device := structs.Device{
+		ID:      id,
+		Name:    strings.SplitN(id, "-", 3)[2],
+		Address: strings.ToLower(id) + ".test",
+		Ports:   ports,
+	}
+
+	for _, role := range roles {
+		device.Roles = append(device.Roles, structs.Role{ID: role})
+	}
+
+	device.Type.ID = "testdevice"
+	device.Type.Output = structs.HasRole(device, "VideoOut") || structs.HasRole(device, "AudioOut")
+	device.Type.Input = structs.HasRole(device, "VideoIn") || structs.HasRole(device, "Microphone")
+	if endpoints != nil {
+		device.Type.Commands = s.Commands(endpoints)
+	}
+
+	return device
+}
+
+func evaluators(keys ...string) []structs.Evaluator {
+	var evaluators []structs.Evaluator
+	for i, key := range keys {
+		evaluators = append(evaluators, structs.Evaluator{ID: key, CodeKey: key, Priority: i})
+	}
+
+	return evaluators
+}
+
+func port(id, source, destination string) structs.Port {
+	return structs.Port{ID: id, SourceDevice: source, DestinationDevice: destination}
+}
+
+// DefaultRoom is ITB-1101, a room with two displays that each switch between two inputs themselves.
+func (s *Server) DefaultRoom() structs.Room {
+	return structs.Room{
+		ID:   "ITB-1101",
+		Name: "ITB-1101",
+		Configuration: structs.RoomConfiguration{
+			ID:          "Default",
+			Description: "Default",
+			Evaluators: evaluators(
+				"PowerOnDefault", "StandbyDefault", "ChangeVideoInputDefault", "BlankDisplayDefault", "UnBlankDisplayDefault",
+				"MuteDefault", "UnMuteDefault", "SetVolumeDefault",
+				"STATUS_PowerDefault", "STATUS_InputDefault", "STATUS_BlankedDefault", "STATUS_MutedDefault", "STATUS_VolumeDefault",
+			),
+		},
+		Devices: []structs.Device{
+			s.newDevice("ITB-1101-D1", []string{"VideoOut", "AudioOut"}, DisplayEndpoints,
+				port("hdmi1", "ITB-1101-HDMI1", "ITB-1101-D1"),
+				port("hdmi2", "ITB-1101-HDMI2", "ITB-1101-D1"),
+			),
+			s.newDevice("ITB-1101-D2", []string{"VideoOut", "AudioOut"}, DisplayEndpoints,
+				port("hdmi1", "ITB-1101-HDMI1", "ITB-1101-D2"),
+				port("hdmi2", "ITB-1101-HDMI2", "ITB-1101-D2"),
+			),
+			s.newDevice("ITB-1101-HDMI1", []string{"VideoIn"}, nil),
+			s.newDevice("ITB-1101-HDMI2", []string{"VideoIn"}, nil),
+		},
+	}
+}
+
+// DSPRoom is ITB-1102, a room with a display and a microphone whose audio goes through a DSP.
+func (s *Server) DSPRoom() structs.Room {
+	return structs.Room{
+		ID:   "ITB-1102",
+		Name: "ITB-1102",
+		Configuration: structs.RoomConfiguration{
+			ID:          "Default",
+			Description: "Default",
+			Evaluators: evaluators(
+				"PowerOnDefault", "StandbyDefault", "SetVolumeDSP", "MuteDSP", "UnmuteDSP",
+				"STATUS_PowerDefault", "STATUS_VolumeDSP", "STATUS_MutedDSP",
+			),
+		},
+		Devices: []structs.Device{
+			s.newDevice("ITB-1102-D1", []string{"VideoOut"}, DisplayEndpoints),
+			s.newDevice("ITB-1102-DSP1", []string{"DSP", "AudioOut"}, DSPEndpoints,
+				port("1", "ITB-1102-MIC1", "ITB-1102-DSP1"),
+				port("2", "ITB-1102-D1", "ITB-1102-DSP1"),
+			),
+			s.newDevice("ITB-1102-MIC1", []string{"Microphone"}, nil),
+		},
+	}
+}
+
+// VideoSwitcherRoom is ITB-1103, a room with a display whose input is picked by a video switcher.
+func (s *Server) VideoSwitcherRoom() structs.Room {
+	return structs.Room{
+		ID:   "ITB-1103",
+		Name: "ITB-1103",
+		Configuration: structs.RoomConfiguration{
+			ID:          "Default",
+			Description: "Default",
+			Evaluators: evaluators(
+				"PowerOnDefault", "StandbyDefault", "ChangeVideoInputVideoSwitcher",
+				"STATUS_PowerDefault",
+			),
+		},
+		Devices: []structs.Device{
+			s.newDevice("ITB-1103-D1", []string{"VideoOut"}, DisplayEndpoints),
+			s.newDevice("ITB-1103-SW1", []string{"VideoSwitcher"}, SwitcherEndpoints,
+				port("1:1", "ITB-1103-HDMI1", "ITB-1103-D1"),
+				port("2:1", "ITB-1103-HDMI2", "ITB-1103-D1"),
+			),
+			s.newDevice("ITB-1103-HDMI1", []string{"VideoIn"}, nil),
+			s.newDevice("ITB-1103-HDMI2", []string{"VideoIn"}, nil),
+		},
+	}
+}
+
+// TieredRoom is ITB-1104, a room with two displays fed by two tiers of video switchers: HDMI1 and HDMI2 go through
+// SW1, which feeds SW2 along with HDMI3.
+func (s *Server) TieredRoom() structs.Room {
+	return structs.Room{
+		ID:   "ITB-1104",
+		Name: "ITB-1104",
+		Configuration: structs.RoomConfiguration{
+			ID:          "Default",
+			Description: "Default",
+			Evaluators: evaluators(
+				"PowerOnDefault", "StandbyDefault", "ChangeVideoInputTieredSwitcher",
+				"STATUS_PowerDefault", "STATUS_Tiered_Switching",
+			),
+		},
+		Devices: []structs.Device{
+			s.newDevice("ITB-1104-D1", []string{"VideoOut"}, DisplayEndpoints,
+				port("hdmi1", "ITB-1104-SW2", "ITB-1104-D1"),
+			),
+			s.newDevice("ITB-1104-D2", []string{"VideoOut"}, DisplayEndpoints,
+				port("hdmi1", "ITB-1104-SW2", "ITB-1104-D2"),
+			),
+			s.newDevice("ITB-1104-SW1", []string{"VideoSwitcher"}, SwitcherEndpoints,
+				port("IN1", "ITB-1104-HDMI1", "ITB-1104-SW1"),
+				port("IN2", "ITB-1104-HDMI2", "ITB-1104-SW1"),
+				port("OUT1", "ITB-1104-SW1", "ITB-1104-SW2"),
+			),
+			s.newDevice("ITB-1104-SW2", []string{"VideoSwitcher"}, SwitcherEndpoints,
+				port("IN1", "ITB-1104-SW1", "ITB-1104-SW2"),
+				port("IN2", "ITB-1104-HDMI3", "ITB-1104-SW2"),
+				port("OUT1", "ITB-1104-SW2", "ITB-1104-D1"),
+				port("OUT2", "ITB-1104-SW2", "ITB-1104-D2"),
+			),
+			s.newDevice("ITB-1104-HDMI1", []string{"VideoIn"}, nil),
+			s.newDevice("ITB-1104-HDMI2", []string{"VideoIn"}, nil),
+			s.newDevice("ITB-1104-HDMI3", []string{"VideoIn"}, nil),
+		},
+	}
+}
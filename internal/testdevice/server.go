@@ -0,0 +1,343 @@
+// Package testdevice stands in for device microservices and the configuration database, so room state can be set and
+// read end to end in tests without hardware or CouchDB.
+package testdevice
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/byuoitav/common/structs"
+)
+
+// Endpoints maps command IDs to the endpoint path a device type uses for them, in the form BuildCommandURL expects.
+type Endpoints map[string]string
+
+// DisplayEndpoints are the commands of a display or any other device that is controlled by its own address.
+var DisplayEndpoints = Endpoints{
+	"PowerOn":        "/:address/power/on",
+	"Standby":        "/:address/power/standby",
+	"ChangeInput":    "/:address/input/:port",
+	"SetVolume":      "/:address/volume/set/:level",
+	"Mute":           "/:address/volume/mute",
+	"UnMute":         "/:address/volume/unmute",
+	"BlankDisplay":   "/:address/display/blank",
+	"UnblankDisplay": "/:address/display/unblank",
+	"STATUS_Power":   "/:address/power/status",
+	"STATUS_Input":   "/:address/input/current",
+	"STATUS_Volume":  "/:address/volume/level",
+	"STATUS_Muted":   "/:address/volume/mute/status",
+	"STATUS_Blanked": "/:address/display/status",
+}
+
+// SwitcherEndpoints are the commands of a video switcher, which routes inputs to outputs. It reports routes in the
+// input:output form the tiered switching pathfinder reads.
+var SwitcherEndpoints = Endpoints{
+	"ChangeInput":  "/:address/input/:input/:output",
+	"STATUS_Input": "/:address/output/:port/input",
+}
+
+// DSPEndpoints are the commands of a DSP, which controls the volume and mute of each of its inputs.
+var DSPEndpoints = Endpoints{
+	"ChangeInput":      "/:address/input/:input/:output",
+	"SetVolume":        "/:address/input/:input/volume/set/:level",
+	"Mute":             "/:address/input/:input/mute",
+	"UnMute":           "/:address/input/:input/unmute",
+	"STATUS_VolumeDSP": "/:address/input/:input/volume/level",
+	"STATUS_MutedDSP":  "/:address/input/:input/mute/status",
+}
+
+// State is the state of one simulated device.
+type State struct {
+	Power   string
+	Input   string
+	Volume  int
+	Muted   bool
+	Blanked bool
+
+	// Routes maps a switcher's outputs to the input routed to them.
+	Routes map[string]string
+
+	// Channels are a DSP's inputs.
+	Channels map[string]Channel
+}
+
+// Channel is the state of one DSP input.
+type Channel struct {
+	Volume int
+	Muted  bool
+}
+
+func (s State) copy() State {
+	routes := make(map[string]string, len(s.Routes))
+	for output, input := range s.Routes {
+		routes[output] = input
+	}
+
+	channels := make(map[string]Channel, len(s.Channels))
+	for input, channel := range s.Channels {
+		channels[input] = channel
+	}
+
+	s.Routes = routes
+	s.Channels = channels
+	return s
+}
+
+// Server is a fake device microservice. It keeps the state of every device it is sent commands for, by address.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	devices  map[string]*State
+	failing  map[string]int
+	requests []string
+}
+
+type handler func(state *State, r *http.Request) (interface{}, error)
+
+// NewServer starts a fake device microservice. Close it when the test is done.
+func NewServer() *Server {
+	s := &Server{
+		devices: make(map[string]*State),
+		failing: make(map[string]int),
+	}
+
+	handlers := map[string]handler{
+		DisplayEndpoints["PowerOn"]: func(state *State, r *http.Request) (interface{}, error) {
+			state.Power = "on"
+			return map[string]string{"power": state.Power}, nil
+		},
+		DisplayEndpoints["Standby"]: func(state *State, r *http.Request) (interface{}, error) {
+			state.Power = "standby"
+			return map[string]string{"power": state.Power}, nil
+		},
+		DisplayEndpoints["ChangeInput"]: func(state *State, r *http.Request) (interface{}, error) {
+			state.Input = r.PathValue("port")
+			return map[string]string{"input": state.Input}, nil
+		},
+		DisplayEndpoints["SetVolume"]: func(state *State, r *http.Request) (interface{}, error) {
+			level, err := level(r)
+			if err != nil {
+				return nil, err
+			}
+
+			state.Volume = level
+			return map[string]int{"volume": state.Volume}, nil
+		},
+		DisplayEndpoints["Mute"]: func(state *State, r *http.Request) (interface{}, error) {
+			state.Muted = true
+			return map[string]bool{"muted": state.Muted}, nil
+		},
+		DisplayEndpoints["UnMute"]: func(state *State, r *http.Request) (interface{}, error) {
+			state.Muted = false
+			return map[string]bool{"muted": state.Muted}, nil
+		},
+		DisplayEndpoints["BlankDisplay"]: func(state *State, r *http.Request) (interface{}, error) {
+			state.Blanked = true
+			return map[string]bool{"blanked": state.Blanked}, nil
+		},
+		DisplayEndpoints["UnblankDisplay"]: func(state *State, r *http.Request) (interface{}, error) {
+			state.Blanked = false
+			return map[string]bool{"blanked": state.Blanked}, nil
+		},
+		DisplayEndpoints["STATUS_Power"]: func(state *State, r *http.Request) (interface{}, error) {
+			return map[string]string{"power": state.Power}, nil
+		},
+		DisplayEndpoints["STATUS_Input"]: func(state *State, r *http.Request) (interface{}, error) {
+			return map[string]string{"input": state.Input}, nil
+		},
+		DisplayEndpoints["STATUS_Volume"]: func(state *State, r *http.Request) (interface{}, error) {
+			return map[string]int{"volume": state.Volume}, nil
+		},
+		DisplayEndpoints["STATUS_Muted"]: func(state *State, r *http.Request) (interface{}, error) {
+			return map[string]bool{"muted": state.Muted}, nil
+		},
+		DisplayEndpoints["STATUS_Blanked"]: func(state *State, r *http.Request) (interface{}, error) {
+			return map[string]bool{"blanked": state.Blanked}, nil
+		},
+		SwitcherEndpoints["ChangeInput"]: func(state *State, r *http.Request) (interface{}, error) {
+			input, output := r.PathValue("input"), r.PathValue("output")
+			state.Routes[output] = input
+			return map[string]string{"input": fmt.Sprintf("%s:%s", input, output)}, nil
+		},
+		SwitcherEndpoints["STATUS_Input"]: func(state *State, r *http.Request) (interface{}, error) {
+			output := r.PathValue("port")
+			input, ok := state.Routes[output]
+			if !ok {
+				return nil, fmt.Errorf("nothing is routed to output %s", output)
+			}
+
+			return map[string]string{"input": fmt.Sprintf("%s:%s", input, output)}, nil
+		},
+		DSPEndpoints["SetVolume"]: func(state *State, r *http.Request) (interface{}, error) {
+			level, err := level(r)
+			if err != nil {
+				return nil, err
+			}
+
+			channel := state.Channels[r.PathValue("input")]
+			channel.Volume = level
+			state.Channels[r.PathValue("input")] = channel
+			return map[string]int{"volume": channel.Volume}, nil
+		},
+		DSPEndpoints["Mute"]: func(state *State, r *http.Request) (interface{}, error) {
+			channel := state.Channels[r.PathValue("input")]
+			channel.Muted = true
+			state.Channels[r.PathValue("input")] = channel
+			return map[string]bool{"muted": channel.Muted}, nil
+		},
+		DSPEndpoints["UnMute"]: func(state *State, r *http.Request) (interface{}, error) {
+			channel := state.Channels[r.PathValue("input")]
+			channel.Muted = false
+			state.Channels[r.PathValue("input")] = channel
+			return map[string]bool{"muted": channel.Muted}, nil
+		},
+		DSPEndpoints["STATUS_VolumeDSP"]: func(state *State, r *http.Request) (interface{}, error) {
+			return map[string]int{"volume": state.Channels[r.PathValue("input")].Volume}, nil
+		},
+		DSPEndpoints["STATUS_MutedDSP"]: func(state *State, r *http.Request) (interface{}, error) {
+			return map[string]bool{"muted": state.Channels[r.PathValue("input")].Muted}, nil
+		},
+	}
+
+	mux := http.NewServeMux()
+	for path, h := range handlers {
+		mux.HandleFunc("GET "+pattern(path), s.handle(h))
+	}
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// pattern converts an endpoint path to a ServeMux pattern, e.g. /:address/power/on to /{address}/power/on.
+func pattern(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + strings.TrimPrefix(segment, ":") + "}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func level(r *http.Request) (int, error) {
+	level, err := strconv.Atoi(r.PathValue("level"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid level %q", r.PathValue("level"))
+	}
+
+	return level, nil
+}
+
+func (s *Server) handle(h handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		address := r.PathValue("address")
+
+		s.mu.Lock()
+		s.requests = append(s.requests, r.URL.Path)
+
+		if code := s.failing[address]; code != 0 {
+			s.mu.Unlock()
+			http.Error(w, fmt.Sprintf("%s is failing", address), code)
+			return
+		}
+
+		resp, err := h(s.device(address), r)
+		s.mu.Unlock()
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// device returns the state of the device at address, creating it if this is the first time it's been used.
+// s.mu must be held.
+func (s *Server) device(address string) *State {
+	state, ok := s.devices[address]
+	if !ok {
+		state = &State{
+			Power:    "standby",
+			Routes:   make(map[string]string),
+			Channels: make(map[string]Channel),
+		}
+		s.devices[address] = state
+	}
+
+	return state
+}
+
+// State returns the state of the device at address.
+func (s *Server) State(address string) State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.device(address).copy()
+}
+
+// SetState changes the state of the device at address without going through the API, as a remote or front panel would.
+func (s *Server) SetState(address string, state State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state = state.copy()
+	s.devices[address] = &state
+}
+
+// Fail makes every request to the device at address fail with statusCode. A statusCode of 0 makes it work again.
+func (s *Server) Fail(address string, statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if statusCode == 0 {
+		delete(s.failing, address)
+		return
+	}
+
+	s.failing[address] = statusCode
+}
+
+// Requests returns the path of every request the server has received, in order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+// Reset forgets every request the server has received.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = nil
+}
+
+// Commands builds the commands for endpoints, sent to this server.
+func (s *Server) Commands(endpoints Endpoints) []structs.Command {
+	var commands []structs.Command
+	for id, path := range endpoints {
+		commands = append(commands, structs.Command{
+			ID:           id,
+			Microservice: structs.Microservice{ID: "testdevice", Address: s.URL},
+			Endpoint:     structs.Endpoint{ID: id, Path: path},
+		})
+	}
+
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].ID < commands[j].ID
+	})
+
+	return commands
+}
@@ -0,0 +1,353 @@
+// Package metrics keeps counters, gauges, and histograms in memory and writes them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the histogram bucket upper bounds, in seconds, used when none are given.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// labelSeparator joins label values into a series key; it can't appear in valid UTF-8 label values.
+const labelSeparator = "\xff"
+
+type metric interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+var registry = struct {
+	sync.Mutex
+	metrics map[string]metric
+}{
+	metrics: make(map[string]metric),
+}
+
+func register(m metric) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.metrics[m.name()]; ok {
+		panic(fmt.Sprintf("metric %s registered twice", m.name()))
+	}
+
+	registry.metrics[m.name()] = m
+}
+
+// Write writes every registered metric to w in the Prometheus text exposition format.
+func Write(w io.Writer) error {
+	registry.Lock()
+	metrics := make([]metric, 0, len(registry.metrics))
+	for _, m := range registry.metrics {
+		metrics = append(metrics, m)
+	}
+	registry.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].name() < metrics[j].name()
+	})
+
+	buf := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(buf)
+	}
+
+	return buf.Flush()
+}
+
+// vec holds one value per combination of label values.
+type vec struct {
+	mu     sync.Mutex
+	n      string
+	help   string
+	typ    string
+	labels []string
+	series map[string][]string
+}
+
+func newVec(name, help, typ string, labels []string) vec {
+	return vec{
+		n:      name,
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		series: make(map[string][]string),
+	}
+}
+
+func (v *vec) name() string {
+	return v.n
+}
+
+// key must be called with the lock held.
+func (v *vec) key(labelValues []string) string {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values", v.n, len(v.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, labelSeparator)
+	if _, ok := v.series[key]; !ok {
+		v.series[key] = append([]string(nil), labelValues...)
+	}
+
+	return key
+}
+
+// sortedKeys must be called with the lock held.
+func (v *vec) sortedKeys() []string {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func (v *vec) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.n, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.n, v.typ)
+}
+
+// labelPairs formats label names and values, plus any extra pairs, as {a="b",...}.
+func (v *vec) labelPairs(values []string, extra ...string) string {
+	var pairs []string
+	for i, label := range v.labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label, escapeLabel(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabel(extra[i+1])))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec is a set of counters partitioned by label values.
+type CounterVec struct {
+	vec
+	values map[string]float64
+}
+
+// NewCounterVec creates and registers a counter.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		vec:    newVec(name, help, "counter", labels),
+		values: make(map[string]float64),
+	}
+
+	register(c)
+	return c
+}
+
+// Inc adds one to the counter with the given label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta, which must not be negative, to the counter with the given label values.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("counter %s can't decrease", c.n))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[c.key(labelValues)] += delta
+}
+
+// Value returns the current value of the counter with the given label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.values[strings.Join(labelValues, labelSeparator)]
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeHeader(w)
+	for _, key := range c.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.n, c.labelPairs(c.series[key]), formatFloat(c.values[key]))
+	}
+}
+
+// GaugeVec is a set of gauges partitioned by label values.
+type GaugeVec struct {
+	vec
+	values map[string]float64
+}
+
+// NewGaugeVec creates and registers a gauge.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{
+		vec:    newVec(name, help, "gauge", labels),
+		values: make(map[string]float64),
+	}
+
+	register(g)
+	return g
+}
+
+// Set sets the gauge with the given label values.
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.values[g.key(labelValues)] = value
+}
+
+// Add adds delta to the gauge with the given label values.
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.values[g.key(labelValues)] += delta
+}
+
+// Value returns the current value of the gauge with the given label values.
+func (g *GaugeVec) Value(labelValues ...string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.values[strings.Join(labelValues, labelSeparator)]
+}
+
+func (g *GaugeVec) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.writeHeader(w)
+	for _, key := range g.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", g.n, g.labelPairs(g.series[key]), formatFloat(g.values[key]))
+	}
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec is a set of histograms partitioned by label values.
+type HistogramVec struct {
+	vec
+	buckets []float64
+	values  map[string]*histogram
+}
+
+// NewHistogramVec creates and registers a histogram. DefaultBuckets are used if buckets is empty.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &HistogramVec{
+		vec:     newVec(name, help, "histogram", labels),
+		buckets: buckets,
+		values:  make(map[string]*histogram),
+	}
+
+	register(h)
+	return h
+}
+
+// Observe adds a value to the histogram with the given label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := h.key(labelValues)
+	hist, ok := h.values[key]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hist
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += value
+}
+
+// Since observes the seconds elapsed since start.
+func (h *HistogramVec) Since(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// Count returns how many values have been observed by the histogram with the given label values.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hist, ok := h.values[strings.Join(labelValues, labelSeparator)]
+	if !ok {
+		return 0
+	}
+
+	return hist.count
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w)
+	for _, key := range h.sortedKeys() {
+		values := h.series[key]
+		hist := h.values[key]
+
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.n, h.labelPairs(values, "le", formatFloat(bound)), hist.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.n, h.labelPairs(values, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.n, h.labelPairs(values), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.n, h.labelPairs(values), hist.count)
+	}
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
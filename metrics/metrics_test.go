@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteExpositionFormat(t *testing.T) {
+	counter := NewCounterVec("test_requests_total", "Requests handled.", "device", "command")
+	gauge := NewGaugeVec("test_queue_depth", "Queued requests.", "room")
+	histogram := NewHistogramVec("test_latency_seconds", "Request latency.", []float64{0.1, 1}, "phase")
+
+	counter.Inc("ITB-1101-D1", "PowerOn")
+	counter.Add(2, "ITB-1101-D1", "PowerOn")
+	counter.Inc(`quo"te`, "Standby")
+	gauge.Set(3, "ITB-1101")
+	gauge.Add(-1, "ITB-1101")
+	histogram.Observe(0.05, "GetRoom")
+	histogram.Observe(0.5, "GetRoom")
+	histogram.Observe(5, "GetRoom")
+
+	var buf bytes.Buffer
+	if err := Write(&buf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	out := buf.String()
+	for _, line := range []string{
+		"# HELP test_requests_total Requests handled.",
+		"# TYPE test_requests_total counter",
+		`test_requests_total{device="ITB-1101-D1",command="PowerOn"} 3`,
+		`test_requests_total{device="quo\"te",command="Standby"} 1`,
+		"# TYPE test_queue_depth gauge",
+		`test_queue_depth{room="ITB-1101"} 2`,
+		"# TYPE test_latency_seconds histogram",
+		`test_latency_seconds_bucket{phase="GetRoom",le="0.1"} 1`,
+		`test_latency_seconds_bucket{phase="GetRoom",le="1"} 2`,
+		`test_latency_seconds_bucket{phase="GetRoom",le="+Inf"} 3`,
+		`test_latency_seconds_sum{phase="GetRoom"} 5.55`,
+		`test_latency_seconds_count{phase="GetRoom"} 3`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("expected output to contain %q, got:\n%s", line, out)
+		}
+	}
+
+	if counter.Value("ITB-1101-D1", "PowerOn") != 3 || histogram.Count("GetRoom") != 3 {
+		t.Fatal("unexpected values read back from metrics")
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	NewCounterVec("test_duplicate_total", "Duplicate.")
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering a duplicate metric to panic")
+		}
+	}()
+
+	NewCounterVec("test_duplicate_total", "Duplicate.")
+}
@@ -0,0 +1,170 @@
+package scenes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/helpers"
+	"github.com/byuoitav/common/log"
+)
+
+// ErrNotFound is returned when a room has no scene by the requested name.
+var ErrNotFound = errors.New("scene not found")
+
+// Scene is a named, partial room state that can be applied to a room.
+type Scene struct {
+	Name string          `json:"name"`
+	Room base.PublicRoom `json:"room"`
+}
+
+// Store holds the scenes for each room and persists them to a JSON file.
+type Store struct {
+	mu     sync.RWMutex
+	path   string
+	scenes map[string]map[string]base.PublicRoom
+}
+
+var (
+	store     *Store
+	storeOnce sync.Once
+)
+
+// GetStore returns the scene store, loading it from scenes.json in the data directory the first time it is called.
+func GetStore() *Store {
+	storeOnce.Do(func() {
+		var err error
+		store, err = NewStore(helpers.DataPath("scenes.json"))
+		if err != nil {
+			log.L.Errorf("[scenes] unable to load scenes, starting empty: %s", err)
+			store = &Store{
+				path:   helpers.DataPath("scenes.json"),
+				scenes: make(map[string]map[string]base.PublicRoom),
+			}
+		}
+	})
+
+	return store
+}
+
+// NewStore builds a store backed by the file at path, loading any scenes already saved there.
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		path:   path,
+		scenes: make(map[string]map[string]base.PublicRoom),
+	}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return s, nil
+	case err != nil:
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(b, &s.scenes); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// List returns the scenes saved for a room, sorted by name.
+func (s *Store) List(roomID string) []Scene {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	scenes := []Scene{}
+	for name, room := range s.scenes[roomID] {
+		scenes = append(scenes, Scene{Name: name, Room: room})
+	}
+
+	sort.Slice(scenes, func(i, j int) bool {
+		return scenes[i].Name < scenes[j].Name
+	})
+
+	return scenes
+}
+
+// Get returns a single scene for a room.
+func (s *Store) Get(roomID string, name string) (Scene, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	room, ok := s.scenes[roomID][name]
+	if !ok {
+		return Scene{}, ErrNotFound
+	}
+
+	return Scene{Name: name, Room: room}, nil
+}
+
+// Put saves a scene for a room, replacing any scene with the same name.
+func (s *Store) Put(roomID string, scene Scene) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.scenes[roomID]; !ok {
+		s.scenes[roomID] = make(map[string]base.PublicRoom)
+	}
+
+	previous, existed := s.scenes[roomID][scene.Name]
+	s.scenes[roomID][scene.Name] = scene.Room
+
+	if err := s.save(); err != nil {
+		if existed {
+			s.scenes[roomID][scene.Name] = previous
+		} else {
+			delete(s.scenes[roomID], scene.Name)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes a scene from a room.
+func (s *Store) Delete(roomID string, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.scenes[roomID][name]
+	if !ok {
+		return ErrNotFound
+	}
+
+	delete(s.scenes[roomID], name)
+	if len(s.scenes[roomID]) == 0 {
+		delete(s.scenes, roomID)
+	}
+
+	if err := s.save(); err != nil {
+		if _, ok := s.scenes[roomID]; !ok {
+			s.scenes[roomID] = make(map[string]base.PublicRoom)
+		}
+		s.scenes[roomID][name] = previous
+
+		return err
+	}
+
+	return nil
+}
+
+// save must be called with the lock held.
+func (s *Store) save() error {
+	b, err := json.MarshalIndent(s.scenes, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err := helpers.WriteFileAtomic(s.path, b); err != nil {
+		return fmt.Errorf("unable to save scenes: %w", err)
+	}
+
+	return nil
+}
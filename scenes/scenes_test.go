@@ -0,0 +1,81 @@
+package scenes
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/common/structs"
+)
+
+func TestStorePersistsScenes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenes.json")
+
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("unable to create store: %s", err)
+	}
+
+	lecture := Scene{
+		Name: "Lecture",
+		Room: base.PublicRoom{Power: "on", CurrentVideoInput: "PC1"},
+	}
+	if err := store.Put("ITB-1101", lecture); err != nil {
+		t.Fatalf("unable to save scene: %s", err)
+	}
+
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("unable to reload store: %s", err)
+	}
+
+	scene, err := reloaded.Get("ITB-1101", "Lecture")
+	if err != nil {
+		t.Fatalf("expected scene to be reloaded: %s", err)
+	}
+	if scene.Room.CurrentVideoInput != "PC1" {
+		t.Fatalf("unexpected reloaded scene %+v", scene)
+	}
+
+	if err := reloaded.Delete("ITB-1101", "Lecture"); err != nil {
+		t.Fatalf("unable to delete scene: %s", err)
+	}
+	if _, err := reloaded.Get("ITB-1101", "Lecture"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestValidateRejectsUnknownDevicesAndValues(t *testing.T) {
+	room := structs.Room{
+		ID: "ITB-1101",
+		Devices: []structs.Device{
+			{ID: "ITB-1101-D1", Name: "D1", Roles: []structs.Role{{ID: "VideoOut"}, {ID: "AudioOut"}}},
+			{ID: "ITB-1101-PC1", Name: "PC1", Roles: []structs.Role{{ID: "VideoIn"}}},
+		},
+	}
+
+	volume := 30
+	valid := Scene{
+		Name: "Lecture",
+		Room: base.PublicRoom{
+			Displays:     []base.Display{{Device: base.Device{Name: "D1", Power: "on", Input: "PC1"}}},
+			AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D1"}, Volume: &volume}},
+		},
+	}
+	if err := Validate(room, valid); err != nil {
+		t.Fatalf("expected scene to be valid: %s", err)
+	}
+
+	loud := 150
+	invalid := Scene{
+		Name: "Broken",
+		Room: base.PublicRoom{
+			Displays:     []base.Display{{Device: base.Device{Name: "D2", Power: "on"}}},
+			AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D1"}, Volume: &loud}},
+		},
+	}
+	if err := Validate(room, invalid); err == nil {
+		t.Fatal("expected scene with an unknown display and invalid volume to be rejected")
+	}
+}
@@ -0,0 +1,123 @@
+package scenes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/common/structs"
+)
+
+// Validate checks that a scene only references devices in the room, and that the values it sets are valid.
+func Validate(room structs.Room, scene Scene) error {
+	if len(strings.TrimSpace(scene.Name)) == 0 {
+		return errors.New("scene name is required")
+	}
+
+	if err := ValidateState(room, scene.Room); err != nil {
+		return fmt.Errorf("invalid scene %s: %w", scene.Name, err)
+	}
+
+	return nil
+}
+
+// ValidateState checks that a room state only references devices in the room, and that the values it sets are valid.
+func ValidateState(room structs.Room, target base.PublicRoom) error {
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if target.Power != "" {
+		if err := validatePower(target.Power); err != nil {
+			addProblem("room: %s", err)
+		}
+	}
+	if target.Volume != nil {
+		if err := validateVolume(*target.Volume); err != nil {
+			addProblem("room: %s", err)
+		}
+	}
+	if target.CurrentVideoInput != "" && findDevice(room, target.CurrentVideoInput) == nil {
+		addProblem("room: video input %s is not a device in %s", target.CurrentVideoInput, room.ID)
+	}
+	if target.CurrentAudioInput != "" && findDevice(room, target.CurrentAudioInput) == nil {
+		addProblem("room: audio input %s is not a device in %s", target.CurrentAudioInput, room.ID)
+	}
+
+	for _, display := range target.Displays {
+		device := findDevice(room, display.Name)
+		switch {
+		case device == nil:
+			addProblem("display %s is not a device in %s", display.Name, room.ID)
+			continue
+		case !device.HasRole("VideoOut"):
+			addProblem("display %s does not have the VideoOut role", display.Name)
+		}
+
+		validateDevice(room, display.Device, addProblem)
+	}
+
+	for _, audioDevice := range target.AudioDevices {
+		device := findDevice(room, audioDevice.Name)
+		switch {
+		case device == nil:
+			addProblem("audio device %s is not a device in %s", audioDevice.Name, room.ID)
+			continue
+		case !device.HasRole("AudioOut") && !device.HasRole("Microphone") && !device.HasRole("DSP"):
+			addProblem("audio device %s does not have the AudioOut, Microphone, or DSP role", audioDevice.Name)
+		}
+
+		validateDevice(room, audioDevice.Device, addProblem)
+		if audioDevice.Volume != nil {
+			if err := validateVolume(*audioDevice.Volume); err != nil {
+				addProblem("audio device %s: %s", audioDevice.Name, err)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func validateDevice(room structs.Room, device base.Device, addProblem func(string, ...interface{})) {
+	if device.Power != "" {
+		if err := validatePower(device.Power); err != nil {
+			addProblem("%s: %s", device.Name, err)
+		}
+	}
+
+	if device.Input != "" && findDevice(room, device.Input) == nil {
+		addProblem("%s: input %s is not a device in %s", device.Name, device.Input, room.ID)
+	}
+}
+
+func validatePower(power string) error {
+	if !strings.EqualFold(power, "on") && !strings.EqualFold(power, "standby") {
+		return fmt.Errorf("%s is not a valid power state", power)
+	}
+
+	return nil
+}
+
+func validateVolume(volume int) error {
+	if volume < 0 || volume > 100 {
+		return fmt.Errorf("%d is not a valid volume level", volume)
+	}
+
+	return nil
+}
+
+func findDevice(room structs.Room, name string) *structs.Device {
+	for i := range room.Devices {
+		if strings.EqualFold(room.Devices[i].Name, name) || strings.EqualFold(room.Devices[i].ID, name) {
+			return &room.Devices[i]
+		}
+	}
+
+	return nil
+}
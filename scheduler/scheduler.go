@@ -0,0 +1,370 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/config"
+	"github.com/byuoitav/av-api/helpers"
+	"github.com/byuoitav/av-api/scenes"
+	"github.com/byuoitav/av-api/state"
+	"github.com/byuoitav/common/log"
+	"github.com/byuoitav/common/v2/events"
+)
+
+// Requestor is the requestor recorded for room state changes made by scheduled jobs.
+const Requestor = "scheduler"
+
+const jobTimeout = 5 * time.Minute
+
+// ErrNotFound is returned when a room has no job with the requested ID.
+var ErrNotFound = errors.New("scheduled job not found")
+
+var (
+	setRoomState = state.SetRoomStateLatest
+	getRoom      = config.GetRoom
+	getScene     = func(roomID string, name string) (scenes.Scene, error) {
+		return scenes.GetStore().Get(roomID, name)
+	}
+)
+
+// Job is a room state change that runs on a cron schedule. A job either applies a scene or sets a room state.
+type Job struct {
+	ID        string           `json:"id"`
+	Name      string           `json:"name,omitempty"`
+	Building  string           `json:"building"`
+	Room      string           `json:"room"`
+	Schedule  string           `json:"schedule"`
+	Scene     string           `json:"scene,omitempty"`
+	State     *base.PublicRoom `json:"state,omitempty"`
+	LastRun   *time.Time       `json:"lastRun,omitempty"`
+	LastError string           `json:"lastError,omitempty"`
+}
+
+// RoomID returns the ID of the room the job runs against.
+func (j Job) RoomID() string {
+	return fmt.Sprintf("%s-%s", j.Building, j.Room)
+}
+
+// Scheduler runs jobs as their schedules come due and persists them to a JSON file.
+type Scheduler struct {
+	mu        sync.Mutex
+	path      string
+	jobs      map[string]*Job
+	schedules map[string]Schedule
+}
+
+var (
+	scheduler     *Scheduler
+	schedulerOnce sync.Once
+)
+
+// GetScheduler returns the scheduler, loading its jobs from schedule.json in the data directory the first time it is called.
+func GetScheduler() *Scheduler {
+	schedulerOnce.Do(func() {
+		var err error
+		scheduler, err = NewScheduler(helpers.DataPath("schedule.json"))
+		if err != nil {
+			log.L.Errorf("[scheduler] unable to load scheduled jobs, starting empty: %s", err)
+			scheduler = &Scheduler{
+				path:      helpers.DataPath("schedule.json"),
+				jobs:      make(map[string]*Job),
+				schedules: make(map[string]Schedule),
+			}
+		}
+	})
+
+	return scheduler
+}
+
+// NewScheduler builds a scheduler backed by the file at path, loading any jobs already saved there.
+func NewScheduler(path string) (*Scheduler, error) {
+	s := &Scheduler{
+		path:      path,
+		jobs:      make(map[string]*Job),
+		schedules: make(map[string]Schedule),
+	}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return s, nil
+	case err != nil:
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	var jobs []*Job
+	if err := json.Unmarshal(b, &jobs); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+
+	for _, job := range jobs {
+		schedule, err := ParseSchedule(job.Schedule)
+		if err != nil {
+			log.L.Errorf("[scheduler] skipping job %s: %s", job.ID, err)
+			continue
+		}
+
+		s.jobs[job.ID] = job
+		s.schedules[job.ID] = schedule
+	}
+
+	return s, nil
+}
+
+// List returns the jobs for a room, sorted by ID.
+func (s *Scheduler) List(roomID string) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := []Job{}
+	for _, job := range s.jobs {
+		if job.RoomID() == roomID {
+			jobs = append(jobs, *job)
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].ID < jobs[j].ID
+	})
+
+	return jobs
+}
+
+// Add validates and saves a new job, assigning it an ID. The scene or state the job sets is checked against the room's
+// devices, so a job that can't run is rejected now instead of failing when it comes due.
+func (s *Scheduler) Add(job Job) (Job, error) {
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return Job{}, err
+	}
+
+	if (len(job.Scene) == 0) == (job.State == nil) {
+		return Job{}, errors.New("a scheduled job must have either a scene or a state")
+	}
+
+	if err := validateTarget(job); err != nil {
+		return Job{}, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Job{}, fmt.Errorf("unable to generate job id: %w", err)
+	}
+
+	job.ID = hex.EncodeToString(id)
+	job.LastRun = nil
+	job.LastError = ""
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[job.ID] = &job
+	s.schedules[job.ID] = schedule
+
+	if err := s.save(); err != nil {
+		delete(s.jobs, job.ID)
+		delete(s.schedules, job.ID)
+		return Job{}, err
+	}
+
+	log.L.Infof("[scheduler] added job %s (%s) for %s", job.ID, job.Schedule, job.RoomID())
+	return job, nil
+}
+
+// Delete removes a job from a room.
+func (s *Scheduler) Delete(roomID string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || job.RoomID() != roomID {
+		return ErrNotFound
+	}
+
+	schedule := s.schedules[id]
+	delete(s.jobs, id)
+	delete(s.schedules, id)
+
+	if err := s.save(); err != nil {
+		s.jobs[id] = job
+		s.schedules[id] = schedule
+		return err
+	}
+
+	log.L.Infof("[scheduler] deleted job %s for %s", id, roomID)
+	return nil
+}
+
+// Start runs due jobs at the top of each minute until ctx is canceled.
+func (s *Scheduler) Start(ctx context.Context) {
+	log.L.Info("[scheduler] starting scheduler")
+
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+
+		select {
+		case <-ctx.Done():
+			log.L.Info("[scheduler] stopping scheduler")
+			return
+		case <-time.After(next.Sub(now)):
+		}
+
+		s.runDue(ctx, next)
+	}
+}
+
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	var due []Job
+	for id, schedule := range s.schedules {
+		if schedule.Matches(now) {
+			due = append(due, *s.jobs[id])
+		}
+	}
+	s.mu.Unlock()
+
+	for _, job := range due {
+		go s.run(ctx, job, now)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job, now time.Time) {
+	log.L.Infof("[scheduler] running job %s for %s", job.ID, job.RoomID())
+
+	err := s.execute(ctx, job)
+	if err != nil {
+		log.L.Errorf("[scheduler] job %s for %s failed: %s", job.ID, job.RoomID(), err)
+	}
+
+	publishRun(job, err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.jobs[job.ID]
+	if !ok {
+		return
+	}
+
+	stored.LastRun = &now
+	stored.LastError = ""
+	if err != nil {
+		stored.LastError = err.Error()
+	}
+
+	if err := s.save(); err != nil {
+		log.L.Errorf("[scheduler] unable to record run of job %s: %s", job.ID, err)
+	}
+}
+
+// validateTarget checks that the scene a job applies exists and, like the state a job sets, only references devices in
+// the job's room.
+func validateTarget(job Job) error {
+	room, err := getRoom(job.RoomID())
+	if err != nil {
+		return fmt.Errorf("unable to get room %s: %w", job.RoomID(), err)
+	}
+
+	if job.State != nil {
+		if err := scenes.ValidateState(room, *job.State); err != nil {
+			return fmt.Errorf("invalid state: %w", err)
+		}
+
+		return nil
+	}
+
+	scene, err := getScene(job.RoomID(), job.Scene)
+	if err != nil {
+		return fmt.Errorf("unable to get scene %s: %w", job.Scene, err)
+	}
+
+	return scenes.Validate(room, scene)
+}
+
+func (s *Scheduler) execute(ctx context.Context, job Job) error {
+	var target base.PublicRoom
+	if job.State != nil {
+		target = *job.State
+	} else {
+		scene, err := scenes.GetStore().Get(job.RoomID(), job.Scene)
+		if err != nil {
+			return fmt.Errorf("unable to get scene %s: %w", job.Scene, err)
+		}
+
+		target = scene.Room
+	}
+
+	target.Building = job.Building
+	target.Room = job.Room
+
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+
+	_, err := setRoomState(ctx, target, Requestor)
+	return err
+}
+
+func publishRun(job Job, err error) {
+	name := job.Name
+	if len(name) == 0 {
+		name = job.ID
+	}
+
+	data := map[string]string{
+		"id":       job.ID,
+		"schedule": job.Schedule,
+		"scene":    job.Scene,
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+
+	e := events.Event{
+		AffectedRoom: events.GenerateBasicRoomInfo(job.RoomID()),
+		Key:          "scheduled-job",
+		Value:        name,
+		User:         Requestor,
+		Data:         data,
+	}
+
+	e.AddToTags(events.AutoGenerated)
+	if err != nil {
+		e.AddToTags(events.Error)
+	}
+
+	base.SendEvent(e)
+}
+
+// save must be called with the lock held.
+func (s *Scheduler) save() error {
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].ID < jobs[j].ID
+	})
+
+	b, err := json.MarshalIndent(jobs, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err := helpers.WriteFileAtomic(s.path, b); err != nil {
+		return fmt.Errorf("unable to save scheduled jobs: %w", err)
+	}
+
+	return nil
+}
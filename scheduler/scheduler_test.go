@@ -0,0 +1,163 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/scenes"
+	"github.com/byuoitav/common/structs"
+)
+
+// stubRoom serves ITB-1101, with a display D1, as the only room, and a "lecture" scene for it.
+func stubRoom(t *testing.T) {
+	t.Helper()
+
+	originalGetRoom, originalGetScene := getRoom, getScene
+	t.Cleanup(func() {
+		getRoom, getScene = originalGetRoom, originalGetScene
+	})
+
+	getRoom = func(roomID string) (structs.Room, error) {
+		if roomID != "ITB-1101" {
+			return structs.Room{}, errors.New("room not found")
+		}
+
+		return structs.Room{
+			ID:      "ITB-1101",
+			Devices: []structs.Device{{ID: "ITB-1101-D1", Name: "D1", Roles: []structs.Role{{ID: "VideoOut"}}}},
+		}, nil
+	}
+
+	getScene = func(roomID string, name string) (scenes.Scene, error) {
+		if roomID != "ITB-1101" || name != "lecture" {
+			return scenes.Scene{}, scenes.ErrNotFound
+		}
+
+		return scenes.Scene{Name: name, Room: base.PublicRoom{Power: "on"}}, nil
+	}
+}
+
+func TestScheduleMatchesWeekdayEvening(t *testing.T) {
+	schedule, err := ParseSchedule("0 23 * * mon-fri")
+	if err != nil {
+		t.Fatalf("unable to parse schedule: %s", err)
+	}
+
+	friday := time.Date(2026, time.October, 16, 23, 0, 0, 0, time.Local)
+	saturday := time.Date(2026, time.October, 17, 23, 0, 0, 0, time.Local)
+	fridayLate := time.Date(2026, time.October, 16, 23, 1, 0, 0, time.Local)
+
+	if !schedule.Matches(friday) {
+		t.Fatal("expected schedule to match friday at 23:00")
+	}
+	if schedule.Matches(saturday) {
+		t.Fatal("expected schedule not to match saturday")
+	}
+	if schedule.Matches(fridayLate) {
+		t.Fatal("expected schedule not to match 23:01")
+	}
+
+	for _, expression := range []string{"0 23 * *", "60 * * * *", "*/0 * * * *", "0 0 * * funday"} {
+		if _, err := ParseSchedule(expression); err == nil {
+			t.Fatalf("expected %q to be rejected", expression)
+		}
+	}
+}
+
+func TestRunDueSetsRoomStateAndRecordsRun(t *testing.T) {
+	stubRoom(t)
+
+	originalSetRoomState := setRoomState
+	defer func() {
+		setRoomState = originalSetRoomState
+	}()
+
+	targets := make(chan base.PublicRoom, 1)
+	requestors := make(chan string, 1)
+	setRoomState = func(ctx context.Context, target base.PublicRoom, requestor string) (base.PublicRoom, error) {
+		targets <- target
+		requestors <- requestor
+		return target, nil
+	}
+
+	s, err := NewScheduler(filepath.Join(t.TempDir(), "schedule.json"))
+	if err != nil {
+		t.Fatalf("unable to create scheduler: %s", err)
+	}
+
+	job, err := s.Add(Job{
+		Building: "ITB",
+		Room:     "1101",
+		Schedule: "0 23 * * *",
+		State:    &base.PublicRoom{Power: "standby"},
+	})
+	if err != nil {
+		t.Fatalf("unable to add job: %s", err)
+	}
+
+	now := time.Date(2026, time.October, 16, 23, 0, 0, 0, time.Local)
+	s.runDue(context.Background(), now)
+
+	select {
+	case target := <-targets:
+		if target.Building != "ITB" || target.Room != "1101" || target.Power != "standby" {
+			t.Fatalf("unexpected target %+v", target)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for job to run")
+	}
+	if requestor := <-requestors; requestor != Requestor {
+		t.Fatalf("expected requestor %q, got %q", Requestor, requestor)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if jobs := s.List(job.RoomID()); len(jobs) == 1 && jobs[0].LastRun != nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("expected job run to be recorded")
+}
+
+func TestAddRejectsJobsThatCantRun(t *testing.T) {
+	stubRoom(t)
+
+	s, err := NewScheduler(filepath.Join(t.TempDir(), "schedule.json"))
+	if err != nil {
+		t.Fatalf("unable to create scheduler: %s", err)
+	}
+
+	tests := map[string]Job{
+		"missing scene":    {Building: "ITB", Room: "1101", Schedule: "0 7 * * *", Scene: "exam"},
+		"unknown room":     {Building: "ITB", Room: "1102", Schedule: "0 7 * * *", Scene: "lecture"},
+		"unknown device":   {Building: "ITB", Room: "1101", Schedule: "0 7 * * *", State: &base.PublicRoom{Displays: []base.Display{{Device: base.Device{Name: "D9", Power: "on"}}}}},
+		"invalid power":    {Building: "ITB", Room: "1101", Schedule: "0 7 * * *", State: &base.PublicRoom{Power: "off"}},
+		"scene and state":  {Building: "ITB", Room: "1101", Schedule: "0 7 * * *", Scene: "lecture", State: &base.PublicRoom{Power: "on"}},
+		"invalid schedule": {Building: "ITB", Room: "1101", Schedule: "0 7 * *", Scene: "lecture"},
+	}
+
+	for name, job := range tests {
+		if _, err := s.Add(job); err == nil {
+			t.Errorf("%s: expected the job to be rejected", name)
+		}
+	}
+
+	if _, err := s.Add(tests["unknown device"]); err == nil || !strings.Contains(err.Error(), "D9") {
+		t.Fatalf("expected the error to name the unknown device, got %v", err)
+	}
+
+	if jobs := s.List("ITB-1101"); len(jobs) != 0 {
+		t.Fatalf("expected no jobs to be saved, got %+v", jobs)
+	}
+
+	if _, err := s.Add(Job{Building: "ITB", Room: "1101", Schedule: "0 7 * * *", Scene: "lecture"}); err != nil {
+		t.Fatalf("unable to add a job for an existing scene: %s", err)
+	}
+}
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/av-api/handlers"
 	"github.com/byuoitav/av-api/health"
+	"github.com/byuoitav/av-api/helpers"
 	avapi "github.com/byuoitav/av-api/init"
+	"github.com/byuoitav/av-api/scheduler"
+	"github.com/byuoitav/av-api/state"
 	hub "github.com/byuoitav/central-event-system/hub/base"
 	"github.com/byuoitav/central-event-system/messenger"
 	"github.com/byuoitav/common"
@@ -21,6 +28,10 @@ import (
 func main() {
 	var nerr *nerr.E
 
+	if err := base.StartEventSpool(helpers.DataPath("event-spool")); err != nil {
+		log.L.Errorf("unable to start event spool; events will be dropped while the hub is unreachable: %s", err)
+	}
+
 	base.Messenger, nerr = messenger.BuildMessenger(os.Getenv("HUB_ADDRESS"), hub.Messenger, 1000)
 	if nerr != nil {
 		log.L.Errorf("unable to connect to the hub: %s", nerr.String())
@@ -34,18 +45,86 @@ func main() {
 		}
 	}()
 
+	// keep the room's configuration saved locally in case the database goes down
+	if len(os.Getenv("ROOM_SYSTEM")) > 0 {
+		go config.StartRefresh(context.Background(), config.SystemRoomID(), config.RefreshInterval)
+	}
+
+	// keep a snapshot of the room's state to serve reads from, and publish changes made outside the API
+	if split := strings.Split(config.SystemRoomID(), "-"); len(os.Getenv("ROOM_SYSTEM")) > 0 && len(split) == 2 {
+		interval := state.SnapshotInterval
+		if env := os.Getenv("ROOM_STATE_SNAPSHOT_INTERVAL"); len(env) > 0 {
+			d, err := time.ParseDuration(env)
+			if err != nil {
+				log.L.Errorf("invalid ROOM_STATE_SNAPSHOT_INTERVAL %q: %s", env, err)
+			} else {
+				interval = d
+			}
+		}
+
+		go state.StartSnapshotPoller(context.Background(), split[0], split[1], interval)
+	}
+
+	if ttl := os.Getenv("CONFIG_CACHE_TTL"); len(ttl) > 0 {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			log.L.Errorf("invalid CONFIG_CACHE_TTL %q: %s", ttl, err)
+		} else {
+			config.SetCacheTTL(d)
+		}
+	}
+
+	if path := os.Getenv("RETRY_POLICY_FILE"); len(path) > 0 {
+		if err := state.LoadRetryPolicies(path); err != nil {
+			log.L.Errorf("unable to load retry policies: %s", err)
+		}
+	}
+
 	port := ":8000"
 	router := common.NewRouter()
+	router.Use(handlers.ConfigHeaders)
 
 	router.GET("/mstatus", databasestatus.Handler)
 	router.GET("/status", databasestatus.Handler)
+	router.GET("/metrics", handlers.GetMetrics)
 
 	// PUT requests
 	router.PUT("/buildings/:building/rooms/:room", handlers.SetRoomState, auth.AuthorizeRequest("write-state", "room", handlers.GetRoomResource))
+	router.PUT("/buildings/:building/rooms/:room/plan", handlers.PlanRoomState, auth.AuthorizeRequest("read-state", "room", handlers.GetRoomResource))
+	router.PUT("/buildings/:building/rooms/:room/devices/:device", handlers.SetDeviceState, auth.AuthorizeRequest("write-state", "room", handlers.GetRoomResource))
 
 	// room status
 	router.GET("/buildings/:building/rooms/:room", handlers.GetRoomState, auth.AuthorizeRequest("read-state", "room", handlers.GetRoomResource))
+	router.GET("/buildings/:building/rooms/:room/devices/:device", handlers.GetDeviceState, auth.AuthorizeRequest("read-state", "room", handlers.GetRoomResource))
+	router.GET("/buildings/:building/rooms/:room/subscribe", handlers.SubscribeRoomState, auth.AuthorizeRequest("read-state", "room", handlers.GetRoomResource))
 	router.GET("/buildings/:building/rooms/:room/configuration", handlers.GetRoomByNameAndBuilding, auth.AuthorizeRequest("read-config", "room", handlers.GetRoomResource))
+	router.GET("/buildings/:building/rooms/:room/configuration/validate", handlers.ValidateRoomConfiguration, auth.AuthorizeRequest("read-config", "room", handlers.GetRoomResource))
+	router.POST("/buildings/:building/rooms/:room/configuration/refresh", handlers.RefreshRoomConfiguration, auth.AuthorizeRequest("write-config", "room", handlers.GetRoomResource))
+
+	// queued and asynchronous room state changes
+	router.GET("/jobs/:id", handlers.GetRoomStateJob, handlers.RequireRoomStateJob, auth.AuthorizeRequest("read-state", "room", handlers.GetJobResource))
+	router.DELETE("/jobs/:id", handlers.CancelRoomStateJob, handlers.RequireRoomStateJob, auth.AuthorizeRequest("write-state", "room", handlers.GetJobResource))
+	router.GET("/buildings/:building/rooms/:room/queue", handlers.GetRoomStateQueue, auth.AuthorizeRequest("read-state", "room", handlers.GetRoomResource))
+	router.DELETE("/buildings/:building/rooms/:room/queue/:id", handlers.CancelQueuedRoomState, auth.AuthorizeRequest("write-state", "room", handlers.GetRoomResource))
+
+	// room state audit log
+	router.GET("/buildings/:building/rooms/:room/audit", handlers.GetRoomStateAudit, auth.AuthorizeRequest("read-state", "room", handlers.GetRoomResource))
+
+	// scenes
+	router.GET("/buildings/:building/rooms/:room/scenes", handlers.GetScenes, auth.AuthorizeRequest("read-config", "room", handlers.GetRoomResource))
+	router.GET("/buildings/:building/rooms/:room/scenes/:name", handlers.GetScene, auth.AuthorizeRequest("read-config", "room", handlers.GetRoomResource))
+	router.PUT("/buildings/:building/rooms/:room/scenes/:name", handlers.SetScene, auth.AuthorizeRequest("write-config", "room", handlers.GetRoomResource))
+	router.DELETE("/buildings/:building/rooms/:room/scenes/:name", handlers.DeleteScene, auth.AuthorizeRequest("write-config", "room", handlers.GetRoomResource))
+	router.POST("/buildings/:building/rooms/:room/scenes/:name", handlers.ApplyScene, auth.AuthorizeRequest("write-state", "room", handlers.GetRoomResource))
+
+	// scheduled jobs only run on room systems
+	if len(os.Getenv("ROOM_SYSTEM")) > 0 {
+		go scheduler.GetScheduler().Start(context.Background())
+
+		router.GET("/buildings/:building/rooms/:room/schedule", handlers.GetScheduledJobs, auth.AuthorizeRequest("read-config", "room", handlers.GetRoomResource))
+		router.POST("/buildings/:building/rooms/:room/schedule", handlers.AddScheduledJob, auth.AuthorizeRequest("write-config", "room", handlers.GetRoomResource))
+		router.DELETE("/buildings/:building/rooms/:room/schedule/:id", handlers.DeleteScheduledJob, auth.AuthorizeRequest("write-config", "room", handlers.GetRoomResource))
+	}
 
 	router.PUT("/log-level/:level", log.SetLogLevel)
 	router.GET("/log-level", log.GetLogLevel)
@@ -0,0 +1,179 @@
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/helpers"
+	"github.com/byuoitav/common/log"
+	"github.com/fatih/color"
+)
+
+const (
+	// auditLogMaxSize is the size a room's audit log can grow to before it's rotated. One rotated log is kept, so a room
+	// keeps between one and two times this much history.
+	auditLogMaxSize = 16 << 20
+
+	// auditLogLineLimit caps the size of a single record read back from an audit log.
+	auditLogLineLimit = 4 << 20
+)
+
+// AuditRecord is a room state change, as recorded in the room's audit log. Request is the body of the request, before
+// its relative fields were resolved. Actions are the reconciled actions generated for it, and Outcomes what happened to
+// each one that was sent or skipped. Report is set if the change succeeded, and Error if it failed.
+type AuditRecord struct {
+	Time      time.Time          `json:"time"`
+	Building  string             `json:"building"`
+	Room      string             `json:"room"`
+	Requestor string             `json:"requestor"`
+	Duration  string             `json:"duration"`
+	Request   base.PublicRoom    `json:"request"`
+	Actions   []PlannedAction    `json:"actions,omitempty"`
+	Outcomes  []base.ActionTrace `json:"outcomes,omitempty"`
+	Report    *base.PublicRoom   `json:"report,omitempty"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// AuditFilter selects the records read back from an audit log. A zero Since or empty Requestor matches every record.
+type AuditFilter struct {
+	Since     time.Time
+	Requestor string
+}
+
+func (f AuditFilter) matches(record AuditRecord) bool {
+	if !f.Since.IsZero() && record.Time.Before(f.Since) {
+		return false
+	}
+
+	return len(f.Requestor) == 0 || record.Requestor == f.Requestor
+}
+
+// auditLogMu serializes writes to the audit logs, and rotations against reads.
+var auditLogMu sync.RWMutex
+
+// auditLogPath returns the path to a room's audit log in the data directory. The rotated log is the same path with a
+// ".1" before the extension.
+func auditLogPath(building string, roomName string) string {
+	return helpers.DataPath(filepath.Join("audit", fmt.Sprintf("%s-%s.jsonl", building, roomName)))
+}
+
+func rotatedAuditLogPath(building string, roomName string) string {
+	return helpers.DataPath(filepath.Join("audit", fmt.Sprintf("%s-%s.1.jsonl", building, roomName)))
+}
+
+// AppendAuditRecord appends a record to its room's audit log, rotating the log first if it's full.
+func AppendAuditRecord(record AuditRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("unable to marshal audit record: %w", err)
+	}
+
+	path := auditLogPath(record.Building, record.Room)
+
+	auditLogMu.Lock()
+	defer auditLogMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("unable to create audit log directory: %w", err)
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Size()+int64(len(b)) > auditLogMaxSize {
+		if err := os.Rename(path, rotatedAuditLogPath(record.Building, record.Room)); err != nil {
+			return fmt.Errorf("unable to rotate %s: %w", path, err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("unable to write to %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// GetAuditRecords reads back the records in a room's audit log that filter selects, oldest first.
+func GetAuditRecords(building string, roomName string, filter AuditFilter) ([]AuditRecord, error) {
+	auditLogMu.RLock()
+	defer auditLogMu.RUnlock()
+
+	records := []AuditRecord{}
+	for _, path := range []string{rotatedAuditLogPath(building, roomName), auditLogPath(building, roomName)} {
+		var err error
+		records, err = readAuditLog(path, filter, records)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// readAuditLog appends the records in the audit log at path that filter selects to records. A missing log has none,
+// and a line that can't be parsed, like one cut off by a crash, is skipped.
+func readAuditLog(path string, filter AuditFilter, records []AuditRecord) ([]AuditRecord, error) {
+	f, err := os.Open(path)
+	switch {
+	case os.IsNotExist(err):
+		return records, nil
+	case err != nil:
+		return nil, fmt.Errorf("unable to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), auditLogLineLimit)
+
+	for scanner.Scan() {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.L.Warnf("%s", color.HiYellowString("[state] skipping unreadable record in %s: %s", path, err))
+			continue
+		}
+
+		if filter.matches(record) {
+			records = append(records, record)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	return records, nil
+}
+
+// auditRoomState records a room state change in its room's audit log. A failure to record it is logged, but doesn't fail
+// the change.
+func auditRoomState(record AuditRecord, started time.Time, trace *base.Trace, report base.PublicRoom, err error) {
+	record.Duration = time.Since(started).String()
+
+	if trace != nil {
+		for _, action := range trace.Actions {
+			// responses are kept in traces, but would make the log grow too fast
+			action.Response = ""
+			record.Outcomes = append(record.Outcomes, action)
+		}
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		report.Trace = nil
+		record.Report = &report
+	}
+
+	if err := AppendAuditRecord(record); err != nil {
+		log.L.Warnf("%s", color.HiYellowString("[state] unable to record room state change in the audit log: %s", err))
+	}
+}
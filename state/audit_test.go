@@ -0,0 +1,106 @@
+package state
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/internal/testdevice"
+	"github.com/byuoitav/common/structs"
+)
+
+func TestSetRoomStateRecordsEachChangeInTheAuditLog(t *testing.T) {
+	useTestDevices(t, func(s *testdevice.Server) []structs.Room {
+		return []structs.Room{s.DefaultRoom()}
+	})
+
+	before := time.Now()
+
+	if _, err := SetRoomStateWithContext(context.Background(), base.PublicRoom{Building: "ITB", Room: "1101", Power: "on"}, "first"); err != nil {
+		t.Fatalf("unable to set room state: %s", err)
+	}
+
+	// a request with both a volume and a volumeDelta fails before any actions are generated
+	volume, delta := 30, 5
+	if _, err := SetRoomStateWithContext(context.Background(), base.PublicRoom{Building: "ITB", Room: "1101", Volume: &volume, VolumeDelta: &delta}, "second"); err == nil {
+		t.Fatal("expected a request with a volume and a volumeDelta to fail")
+	}
+
+	records, err := GetAuditRecords("ITB", "1101", AuditFilter{})
+	if err != nil {
+		t.Fatalf("unable to read the audit log: %s", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected two records, got %+v", records)
+	}
+
+	first := records[0]
+	if first.Requestor != "first" || first.Request.Power != "on" || first.Time.Before(before) || len(first.Error) > 0 {
+		t.Fatalf("unexpected record for the first change %+v", first)
+	}
+	if first.Report == nil || first.Report.Trace != nil {
+		t.Fatalf("expected the first record to have a report without a trace, got %+v", first.Report)
+	}
+	if len(first.Actions) == 0 || len(first.Outcomes) == 0 {
+		t.Fatalf("expected the first record to have actions and their outcomes, got %+v", first)
+	}
+	for _, outcome := range first.Outcomes {
+		if outcome.StatusCode != 200 || len(outcome.Response) > 0 {
+			t.Fatalf("unexpected outcome %+v", outcome)
+		}
+	}
+
+	second := records[1]
+	if second.Requestor != "second" || second.Report != nil || len(second.Error) == 0 {
+		t.Fatalf("expected the second record to be a failure, got %+v", second)
+	}
+
+	records, err = GetAuditRecords("ITB", "1101", AuditFilter{Requestor: "second"})
+	if err != nil || len(records) != 1 || records[0].Requestor != "second" {
+		t.Fatalf("expected only the second requestor's record, got %+v, %v", records, err)
+	}
+
+	records, err = GetAuditRecords("ITB", "1101", AuditFilter{Since: time.Now()})
+	if err != nil || len(records) != 0 {
+		t.Fatalf("expected no records since now, got %+v, %v", records, err)
+	}
+}
+
+func TestGetAuditRecordsReadsTheRotatedLogFirst(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	older := AuditRecord{Time: time.Now().Add(-time.Hour), Building: "ITB", Room: "1101", Requestor: "older"}
+	newer := AuditRecord{Time: time.Now(), Building: "ITB", Room: "1101", Requestor: "newer"}
+
+	if err := AppendAuditRecord(older); err != nil {
+		t.Fatalf("unable to append: %s", err)
+	}
+	if err := os.Rename(auditLogPath("ITB", "1101"), rotatedAuditLogPath("ITB", "1101")); err != nil {
+		t.Fatalf("unable to rotate: %s", err)
+	}
+	if err := AppendAuditRecord(newer); err != nil {
+		t.Fatalf("unable to append: %s", err)
+	}
+
+	// a record cut off by a crash is skipped
+	f, err := os.OpenFile(auditLogPath("ITB", "1101"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("unable to open the log: %s", err)
+	}
+	f.WriteString(`{"time":"`)
+	f.Close()
+
+	records, err := GetAuditRecords("ITB", "1101", AuditFilter{})
+	if err != nil {
+		t.Fatalf("unable to read the audit log: %s", err)
+	}
+	if len(records) != 2 || records[0].Requestor != "older" || records[1].Requestor != "newer" {
+		t.Fatalf("expected the older and newer records in order, got %+v", records)
+	}
+
+	if records, err := GetAuditRecords("ITB", "1102", AuditFilter{}); err != nil || len(records) != 0 {
+		t.Fatalf("expected a room without a log to have no records, got %+v, %v", records, err)
+	}
+}
@@ -0,0 +1,180 @@
+package state
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/common/log"
+	"github.com/byuoitav/common/structs"
+	"github.com/byuoitav/common/v2/events"
+	"github.com/fatih/color"
+)
+
+const (
+	// breakerFailureThreshold is the number of consecutive failures that marks a device unreachable.
+	breakerFailureThreshold = 3
+
+	// breakerOpenDuration is how long commands to an unreachable device are skipped before it is tried again.
+	breakerOpenDuration = 30 * time.Second
+)
+
+type deviceBreaker struct {
+	failures  int
+	open      bool
+	openUntil time.Time
+}
+
+var deviceBreakers = struct {
+	sync.Mutex
+	devices map[string]*deviceBreaker
+}{
+	devices: make(map[string]*deviceBreaker),
+}
+
+// errDeviceUnreachable builds the error returned for commands skipped because a device is unreachable.
+func errDeviceUnreachable(device structs.Device) error {
+	return fmt.Errorf("device %s is unreachable; skipping command", device.ID)
+}
+
+// breakerAllows reports whether a command should be sent to a device. Once an unreachable device's
+// backoff window has passed, a single command is let through to check whether it has come back.
+func breakerAllows(deviceID string) bool {
+	deviceBreakers.Lock()
+	defer deviceBreakers.Unlock()
+
+	breaker, ok := deviceBreakers.devices[deviceID]
+	if !ok || !breaker.open {
+		return true
+	}
+
+	now := time.Now()
+	if now.Before(breaker.openUntil) {
+		return false
+	}
+
+	breaker.openUntil = now.Add(breakerOpenDuration)
+	return true
+}
+
+// deviceUnreachable reports whether a device's breaker is open.
+func deviceUnreachable(deviceID string) bool {
+	deviceBreakers.Lock()
+	defer deviceBreakers.Unlock()
+
+	breaker, ok := deviceBreakers.devices[deviceID]
+	return ok && breaker.open
+}
+
+// recordCommandResult updates a device's breaker after a command. Transport errors and 5xx responses count as failures.
+func recordCommandResult(device structs.Device, statusCode int, err error) {
+	if err != nil || statusCode >= http.StatusInternalServerError {
+		recordDeviceFailure(device)
+		return
+	}
+
+	recordDeviceSuccess(device)
+}
+
+func recordDeviceFailure(device structs.Device) {
+	deviceBreakers.Lock()
+	breaker, ok := deviceBreakers.devices[device.ID]
+	if !ok {
+		breaker = &deviceBreaker{}
+		deviceBreakers.devices[device.ID] = breaker
+	}
+
+	breaker.failures++
+	opened := false
+	if breaker.failures >= breakerFailureThreshold {
+		opened = !breaker.open
+		breaker.open = true
+		breaker.openUntil = time.Now().Add(breakerOpenDuration)
+	}
+	deviceBreakers.Unlock()
+
+	if opened {
+		log.L.Warnf("%s", color.HiRedString("[state] %s failed %d times in a row; marking it unreachable for %s", device.ID, breakerFailureThreshold, breakerOpenDuration))
+		publishReachability(device, false)
+	}
+}
+
+func recordDeviceSuccess(device structs.Device) {
+	deviceBreakers.Lock()
+	breaker, ok := deviceBreakers.devices[device.ID]
+	if !ok {
+		deviceBreakers.Unlock()
+		return
+	}
+
+	closed := breaker.open
+	delete(deviceBreakers.devices, device.ID)
+	deviceBreakers.Unlock()
+
+	if closed {
+		log.L.Infof("%s", color.HiGreenString("[state] %s is reachable again", device.ID))
+		publishReachability(device, true)
+	}
+}
+
+func publishReachability(device structs.Device, reachable bool) {
+	value := "unreachable"
+	if reachable {
+		value = "reachable"
+	}
+
+	e := events.Event{
+		TargetDevice: events.GenerateBasicDeviceInfo(device.ID),
+		AffectedRoom: events.GenerateBasicRoomInfo(device.GetDeviceRoomID()),
+		Key:          "reachability",
+		Value:        value,
+	}
+
+	e.AddToTags(events.DetailState, events.AutoGenerated)
+
+	base.SendEvent(e)
+}
+
+// markUnreachableDevices flags the devices in a room that are currently unreachable, adding them to the report if they are missing.
+func markUnreachableDevices(room structs.Room, status *base.PublicRoom) {
+	for _, device := range room.Devices {
+		if !deviceUnreachable(device.ID) {
+			continue
+		}
+
+		if device.HasRole("VideoOut") {
+			found := false
+			for i := range status.Displays {
+				if strings.EqualFold(status.Displays[i].Name, device.Name) {
+					status.Displays[i].Unreachable = true
+					found = true
+				}
+			}
+
+			if !found {
+				status.Displays = append(status.Displays, base.Display{
+					Device: base.Device{Name: device.Name, Unreachable: true},
+				})
+			}
+		}
+
+		if device.HasRole("AudioOut") {
+			found := false
+			for i := range status.AudioDevices {
+				if strings.EqualFold(status.AudioDevices[i].Name, device.Name) {
+					status.AudioDevices[i].Unreachable = true
+					found = true
+				}
+			}
+
+			if !found {
+				status.AudioDevices = append(status.AudioDevices, base.AudioDevice{
+					Device: base.Device{Name: device.Name, Unreachable: true},
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package state
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/byuoitav/av-api/base"
+	se "github.com/byuoitav/av-api/statusevaluators"
+	"github.com/byuoitav/common/structs"
+)
+
+func TestIssueCommandsSkipsDeviceAfterRepeatedFailures(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	device := structs.Device{
+		ID:      "TEST-BREAKER-D1",
+		Name:    "D1",
+		Address: "127.0.0.1",
+		Roles:   []structs.Role{{ID: "VideoOut"}},
+		Type: structs.DeviceType{
+			Commands: []structs.Command{
+				statusCommand("STATUS_PowerDefault", server.URL, "/power"),
+			},
+		},
+	}
+	defer recordDeviceSuccess(device)
+
+	for i := 0; i < breakerFailureThreshold+2; i++ {
+		channel := make(chan []se.StatusResponse, 1)
+		var group sync.WaitGroup
+		group.Add(1)
+		issueCommands(context.Background(), []se.StatusCommand{statusTestCommand(device, "STATUS_PowerDefault")}, channel, &group)
+		group.Wait()
+
+		responses := <-channel
+		if len(responses) != 1 || responses[0].ErrorMessage == nil {
+			t.Fatalf("expected a failed response on attempt %d, got %+v", i, responses)
+		}
+	}
+
+	if got := atomic.LoadInt32(&requests); got != breakerFailureThreshold {
+		t.Fatalf("expected %d requests before the breaker opened, got %d", breakerFailureThreshold, got)
+	}
+
+	if !deviceUnreachable(device.ID) {
+		t.Fatal("expected device to be marked unreachable")
+	}
+
+	var status base.PublicRoom
+	markUnreachableDevices(structs.Room{Devices: []structs.Device{device}}, &status)
+	if len(status.Displays) != 1 || !status.Displays[0].Unreachable {
+		t.Fatalf("expected display to be reported unreachable, got %+v", status.Displays)
+	}
+
+	recordDeviceSuccess(device)
+	if deviceUnreachable(device.ID) || !breakerAllows(device.ID) {
+		t.Fatal("expected a success to close the breaker")
+	}
+}
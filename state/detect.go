@@ -0,0 +1,103 @@
+package state
+
+import (
+	"strconv"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/common/v2/events"
+)
+
+// DetectionEvent tags events for changes found on a device, such as someone using a projector's remote, rather than
+// changes made through the API.
+const DetectionEvent = "detection-event"
+
+// DetectedChange is the Data of a detection event.
+type DetectedChange struct {
+	Device   string `json:"device"`
+	Field    string `json:"field"`
+	Previous string `json:"previous"`
+	Current  string `json:"current"`
+}
+
+type detectedFields map[string]string
+
+// DetectChanges compares two reads of a room's state and returns an event for each device field that changed.
+// Fields that are missing from either read, and devices that couldn't be reached by the current one, are skipped, since
+// a failed read isn't a change. Fields a device reports as both a display and an audio device only get one event.
+func DetectChanges(roomID string, previous base.PublicRoom, current base.PublicRoom) []events.Event {
+	var detected []events.Event
+	seen := make(map[string]bool)
+
+	detect := func(name string, previousFields detectedFields, currentFields detectedFields) {
+		for _, field := range []string{"power", "input", "blanked", "muted", "volume"} {
+			before, after := previousFields[field], currentFields[field]
+			if len(before) == 0 || len(after) == 0 || before == after || seen[name+"/"+field] {
+				continue
+			}
+			seen[name+"/"+field] = true
+
+			e := events.Event{
+				Key:          field,
+				Value:        after,
+				AffectedRoom: events.GenerateBasicRoomInfo(roomID),
+				TargetDevice: events.GenerateBasicDeviceInfo(roomID + "-" + name),
+				Data: DetectedChange{
+					Device:   name,
+					Field:    field,
+					Previous: before,
+					Current:  after,
+				},
+			}
+			e.AddToTags(events.CoreState, DetectionEvent)
+
+			detected = append(detected, e)
+		}
+	}
+
+	for _, display := range current.Displays {
+		old, ok := findDisplay(previous, display.Name)
+		if !ok || display.Unreachable {
+			continue
+		}
+
+		detect(display.Name, displayFields(old), displayFields(display))
+	}
+
+	for _, audioDevice := range current.AudioDevices {
+		old, ok := findAudioDevice(previous, audioDevice.Name)
+		if !ok || audioDevice.Unreachable {
+			continue
+		}
+
+		detect(audioDevice.Name, audioDeviceFields(old), audioDeviceFields(audioDevice))
+	}
+
+	return detected
+}
+
+func displayFields(display base.Display) detectedFields {
+	fields := detectedFields{
+		"power": display.Power,
+		"input": display.Input,
+	}
+	if display.Blanked != nil {
+		fields["blanked"] = strconv.FormatBool(*display.Blanked)
+	}
+
+	return fields
+}
+
+func audioDeviceFields(audioDevice base.AudioDevice) detectedFields {
+	fields := detectedFields{
+		"power": audioDevice.Power,
+		"input": audioDevice.Input,
+	}
+	if audioDevice.Muted != nil {
+		fields["muted"] = strconv.FormatBool(*audioDevice.Muted)
+	}
+	if audioDevice.Volume != nil {
+		fields["volume"] = strconv.Itoa(*audioDevice.Volume)
+	}
+
+	return fields
+}
@@ -0,0 +1,72 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/common/v2/events"
+)
+
+func TestDetectChangesReportsPreviousAndCurrentValues(t *testing.T) {
+	low, high := 10, 30
+	unmuted := false
+
+	previous := base.PublicRoom{
+		Displays: []base.Display{
+			{Device: base.Device{Name: "D1", Power: "on", Input: "HDMI1"}},
+		},
+		AudioDevices: []base.AudioDevice{
+			{Device: base.Device{Name: "D1", Power: "on", Input: "HDMI1"}, Volume: &low, Muted: &unmuted},
+		},
+	}
+	current := base.PublicRoom{
+		Displays: []base.Display{
+			{Device: base.Device{Name: "D1", Power: "on", Input: "HDMI2"}},
+		},
+		AudioDevices: []base.AudioDevice{
+			{Device: base.Device{Name: "D1", Power: "on", Input: "HDMI2"}, Volume: &high, Muted: &unmuted},
+		},
+	}
+
+	detected := DetectChanges("ITB-1101", previous, current)
+	if len(detected) != 2 {
+		t.Fatalf("expected an input and a volume event, got %+v", detected)
+	}
+
+	want := map[string]DetectedChange{
+		"input":  {Device: "D1", Field: "input", Previous: "HDMI1", Current: "HDMI2"},
+		"volume": {Device: "D1", Field: "volume", Previous: "10", Current: "30"},
+	}
+
+	for _, e := range detected {
+		if e.Data != want[e.Key] {
+			t.Fatalf("unexpected change for %s: %+v", e.Key, e.Data)
+		}
+		if e.Value != want[e.Key].Current || e.TargetDevice.DeviceID != "ITB-1101-D1" {
+			t.Fatalf("unexpected event %+v", e)
+		}
+		if !events.ContainsAllTags(e, events.CoreState, DetectionEvent) || events.ContainsAnyTags(e, events.UserGenerated) {
+			t.Fatalf("expected a core state detection event, got tags %v", e.EventTags)
+		}
+	}
+}
+
+func TestDetectChangesSkipsFailedReads(t *testing.T) {
+	previous := base.PublicRoom{
+		Displays: []base.Display{
+			{Device: base.Device{Name: "D1", Power: "on"}},
+			{Device: base.Device{Name: "D2"}},
+		},
+	}
+	current := base.PublicRoom{
+		Displays: []base.Display{
+			{Device: base.Device{Name: "D1", Unreachable: true}},
+			{Device: base.Device{Name: "D2", Power: "on"}},
+			{Device: base.Device{Name: "D3", Power: "on"}},
+		},
+	}
+
+	if detected := DetectChanges("ITB-1101", previous, current); len(detected) != 0 {
+		t.Fatalf("expected no events, got %+v", detected)
+	}
+}
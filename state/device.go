@@ -0,0 +1,127 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/config"
+	"github.com/byuoitav/common/log"
+	"github.com/byuoitav/common/structs"
+	"github.com/fatih/color"
+)
+
+// GetDeviceStateWithContext reads the state of one device in a room, only running the status commands that report on it.
+func GetDeviceStateWithContext(ctx context.Context, building string, roomName string, deviceName string) (base.DeviceState, error) {
+
+	log.L.Infof("%s", color.HiBlueString("[state] getting state of %s-%s-%s...", building, roomName, deviceName))
+
+	device, err := config.GetDevice(fmt.Sprintf("%s-%s-%s", building, roomName, deviceName))
+	if err != nil {
+		return base.DeviceState{}, err
+	}
+
+	status, err := GetRoomStateWithFilter(ctx, building, roomName, StatusFilter{Devices: []string{device.Name}})
+	if err != nil {
+		if deviceUnreachable(device.ID) {
+			return base.DeviceState{Device: base.Device{Name: device.Name, Unreachable: true}}, nil
+		}
+
+		return base.DeviceState{}, err
+	}
+
+	state, ok := DeviceStateFromRoom(status, device.Name)
+	if !ok {
+		return base.DeviceState{}, fmt.Errorf("no status reported for %s", device.ID)
+	}
+
+	return state, nil
+}
+
+// DeviceStateFromRoom pulls one device's state out of a room's state.
+func DeviceStateFromRoom(room base.PublicRoom, deviceName string) (base.DeviceState, bool) {
+	var state base.DeviceState
+	found := false
+
+	for _, display := range room.Displays {
+		if strings.EqualFold(display.Name, deviceName) {
+			state.Device = display.Device
+			state.Blanked = display.Blanked
+			found = true
+		}
+	}
+
+	for _, audioDevice := range room.AudioDevices {
+		if !strings.EqualFold(audioDevice.Name, deviceName) {
+			continue
+		}
+
+		if !found {
+			state.Device = audioDevice.Device
+		}
+
+		state.Unreachable = state.Unreachable || audioDevice.Unreachable
+		state.Muted = audioDevice.Muted
+		state.Volume = audioDevice.Volume
+		found = true
+	}
+
+	return state, found
+}
+
+// DeviceTarget translates a requested device state into a room state change that only touches that device. Power,
+// input, and blanking go to the device's display entry and muting and volume to its audio entry; devices that aren't
+// displays get a single audio entry.
+func DeviceTarget(building string, roomName string, deviceName string, target base.DeviceState) (base.PublicRoom, error) {
+	device, err := config.GetDevice(fmt.Sprintf("%s-%s-%s", building, roomName, deviceName))
+	if err != nil {
+		return base.PublicRoom{}, err
+	}
+
+	room := base.PublicRoom{
+		Building: building,
+		Room:     roomName,
+	}
+
+	requested := base.Device{
+		Name:  device.Name,
+		Power: target.Power,
+		Input: target.Input,
+	}
+
+	if structs.HasRole(device, "VideoOut") {
+		room.Displays = append(room.Displays, base.Display{
+			Device:        requested,
+			Blanked:       target.Blanked,
+			ToggleBlanked: target.ToggleBlanked,
+		})
+
+		if target.Muted != nil || target.ToggleMuted || target.Volume != nil || target.VolumeDelta != nil {
+			room.AudioDevices = append(room.AudioDevices, base.AudioDevice{
+				Device:      base.Device{Name: device.Name},
+				Muted:       target.Muted,
+				Volume:      target.Volume,
+				VolumeDelta: target.VolumeDelta,
+				ToggleMuted: target.ToggleMuted,
+			})
+		}
+
+		return room, nil
+	}
+
+	if target.Blanked != nil || target.ToggleBlanked {
+		return base.PublicRoom{}, errors.New("only displays can be blanked")
+	}
+
+	room.AudioDevices = append(room.AudioDevices, base.AudioDevice{
+		Device:      requested,
+		Muted:       target.Muted,
+		Volume:      target.Volume,
+		VolumeDelta: target.VolumeDelta,
+		ToggleMuted: target.ToggleMuted,
+	})
+
+	return room, nil
+}
@@ -0,0 +1,63 @@
+package state
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/internal/testdevice"
+	"github.com/byuoitav/common/structs"
+)
+
+func TestDeviceStateOnlyQueriesTheDevice(t *testing.T) {
+	server := useTestDevices(t, func(s *testdevice.Server) []structs.Room {
+		return []structs.Room{s.DefaultRoom()}
+	})
+
+	volume := 25
+	target, err := DeviceTarget("ITB", "1101", "D2", base.DeviceState{
+		Device: base.Device{Power: "on", Input: "HDMI1"},
+		Volume: &volume,
+	})
+	if err != nil {
+		t.Fatalf("unable to build target: %s", err)
+	}
+	if len(target.Displays) != 1 || target.Displays[0].Power != "on" || len(target.AudioDevices) != 1 || target.AudioDevices[0].Power != "" {
+		t.Fatalf("expected power and input on the display and volume on the audio device, got %+v", target)
+	}
+
+	if _, err := SetRoomStateWithContext(context.Background(), target, "test"); err != nil {
+		t.Fatalf("unable to set device state: %s", err)
+	}
+	if state := server.State("itb-1101-d1.test"); state.Power != "standby" {
+		t.Fatalf("expected D1 to be left alone, got %+v", state)
+	}
+
+	server.Reset()
+	status, err := GetDeviceStateWithContext(context.Background(), "ITB", "1101", "D2")
+	if err != nil {
+		t.Fatalf("unable to get device state: %s", err)
+	}
+
+	if status.Name != "D2" || status.Power != "on" || status.Input != "HDMI1" || status.Volume == nil || *status.Volume != 25 || status.Blanked == nil {
+		t.Fatalf("unexpected device state %+v", status)
+	}
+
+	for _, path := range server.Requests() {
+		if !strings.HasPrefix(path, "/itb-1101-d2.test/") {
+			t.Fatalf("expected only D2 to be queried, got a request to %s", path)
+		}
+	}
+}
+
+func TestDeviceTargetRejectsBlankingAudioDevices(t *testing.T) {
+	useTestDevices(t, func(s *testdevice.Server) []structs.Room {
+		return []structs.Room{s.DSPRoom()}
+	})
+
+	blanked := true
+	if _, err := DeviceTarget("ITB", "1102", "MIC1", base.DeviceState{Blanked: &blanked}); err == nil {
+		t.Fatal("expected an error blanking a microphone")
+	}
+}
@@ -0,0 +1,164 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	se "github.com/byuoitav/av-api/statusevaluators"
+	"github.com/byuoitav/common/structs"
+)
+
+// statusEvaluatorFields maps each status evaluator to the room state field it reports.
+var statusEvaluatorFields = map[string]string{
+	"STATUS_PowerDefault":       "power",
+	"STATUS_InputDefault":       "input",
+	"STATUS_InputVideoSwitcher": "input",
+	"STATUS_InputDSP":           "input",
+	"STATUS_Tiered_Switching":   "input",
+	"STATUS_BlankedDefault":     "blanked",
+	"STATUS_MutedDefault":       "muted",
+	"STATUS_MutedDSP":           "muted",
+	"STATUS_VolumeDefault":      "volume",
+	"STATUS_VolumeDSP":          "volume",
+}
+
+// StatusFilter narrows which status commands a room state read runs. The zero value reads the whole room.
+type StatusFilter struct {
+	// Fields are the fields to read, e.g. power or input. Empty means every field.
+	Fields []string
+
+	// Devices are the names of the devices to read. Empty means every device.
+	Devices []string
+}
+
+// ParseStatusFilter builds a StatusFilter from comma separated lists of fields and device names, like the ones in
+// ?fields=power,input&devices=D1,D2.
+func ParseStatusFilter(fields string, devices string) (StatusFilter, error) {
+	var filter StatusFilter
+
+	for _, field := range splitList(fields) {
+		field = strings.ToLower(field)
+		if !knownField(field) {
+			return StatusFilter{}, fmt.Errorf("unknown field %q", field)
+		}
+
+		filter.Fields = append(filter.Fields, field)
+	}
+
+	filter.Devices = splitList(devices)
+	return filter, nil
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func knownField(field string) bool {
+	for _, known := range statusEvaluatorFields {
+		if known == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String is a canonical form of the filter, e.g. ?fields=input,power&devices=d1. It's empty for the zero value.
+func (f StatusFilter) String() string {
+	var params []string
+	if len(f.Fields) > 0 {
+		params = append(params, "fields="+canonicalList(f.Fields))
+	}
+	if len(f.Devices) > 0 {
+		params = append(params, "devices="+canonicalList(f.Devices))
+	}
+
+	if len(params) == 0 {
+		return ""
+	}
+
+	return "?" + strings.Join(params, "&")
+}
+
+func canonicalList(list []string) string {
+	lower := make([]string, len(list))
+	for i := range list {
+		lower[i] = strings.ToLower(list[i])
+	}
+
+	sort.Strings(lower)
+	return strings.Join(lower, ",")
+}
+
+func (f StatusFilter) includesField(field string) bool {
+	if len(f.Fields) == 0 {
+		return true
+	}
+
+	for _, selected := range f.Fields {
+		if strings.EqualFold(selected, field) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f StatusFilter) includesDevice(name string) bool {
+	if len(f.Devices) == 0 {
+		return true
+	}
+
+	for _, device := range f.Devices {
+		if strings.EqualFold(device, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GenerateStatusCommandsWithFilter determines the status commands for the room, only running the status evaluators
+// that report the fields filter selects, and keeping only the commands that report on the devices it selects.
+func GenerateStatusCommandsWithFilter(room structs.Room, commandMap map[string]se.StatusEvaluator, filter StatusFilter) ([]se.StatusCommand, int, error) {
+	if len(filter.Fields) > 0 {
+		var evaluators []structs.Evaluator
+		for _, evaluator := range room.Configuration.Evaluators {
+			if filter.includesField(statusEvaluatorFields[evaluator.CodeKey]) {
+				evaluators = append(evaluators, evaluator)
+			}
+		}
+
+		room.Configuration.Evaluators = evaluators
+	}
+
+	commands, count, err := GenerateStatusCommands(room, commandMap)
+	if err != nil || len(filter.Devices) == 0 {
+		return commands, count, err
+	}
+
+	filtered := FilterStatusCommands(commands, filter)
+	return filtered, len(filtered), nil
+}
+
+// FilterStatusCommands keeps the commands that report on the devices filter selects. Commands with a callback are
+// always kept: callback evaluators (like tiered switching) trace signal paths through other devices, and wait for
+// every command they generated.
+func FilterStatusCommands(commands []se.StatusCommand, filter StatusFilter) []se.StatusCommand {
+	var filtered []se.StatusCommand
+	for _, command := range commands {
+		if command.Callback != nil || filter.includesDevice(command.DestinationDevice.Name) {
+			filtered = append(filtered, command)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,74 @@
+package state
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/internal/testdevice"
+	"github.com/byuoitav/common/structs"
+)
+
+func TestParseStatusFilter(t *testing.T) {
+	filter, err := ParseStatusFilter("Power, input", "D2,D1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := filter.String(); got != "?fields=input,power&devices=d1,d2" {
+		t.Fatalf("unexpected canonical filter %q", got)
+	}
+
+	if filter, _ := ParseStatusFilter("", ""); filter.String() != "" {
+		t.Fatalf("expected an empty filter, got %q", filter.String())
+	}
+
+	if _, err := ParseStatusFilter("power,colour", ""); err == nil {
+		t.Fatal("expected an error for an unknown field")
+	}
+}
+
+func TestGetRoomStateWithFilterOnlyRunsWhatItNeeds(t *testing.T) {
+	server := useTestDevices(t, func(s *testdevice.Server) []structs.Room {
+		return []structs.Room{s.DefaultRoom()}
+	})
+	server.SetState("itb-1101-d1.test", testdevice.State{Power: "on", Input: "hdmi1"})
+
+	status, err := GetRoomStateWithFilter(context.Background(), "ITB", "1101", StatusFilter{Fields: []string{"power"}, Devices: []string{"D1"}})
+	if err != nil {
+		t.Fatalf("unable to get room state: %s", err)
+	}
+
+	if len(status.Displays) != 1 || status.Displays[0].Name != "D1" || status.Displays[0].Power != "on" || status.Displays[0].Input != "" {
+		t.Fatalf("expected only D1's power, got %+v", status.Displays)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0] != "/itb-1101-d1.test/power/status" {
+		t.Fatalf("expected a single power status request, got %v", requests)
+	}
+}
+
+func TestInvalidateRoomStateCacheDropsFilteredReads(t *testing.T) {
+	roomStateRequests.Lock()
+	roomStateRequests.cache["ITB-1105"] = roomStateCacheEntry{status: base.PublicRoom{}, expiresAt: time.Now().Add(time.Minute)}
+	roomStateRequests.cache["ITB-1105?fields=power"] = roomStateCacheEntry{status: base.PublicRoom{}, expiresAt: time.Now().Add(time.Minute)}
+	roomStateRequests.cache["ITB-11050?fields=power"] = roomStateCacheEntry{status: base.PublicRoom{}, expiresAt: time.Now().Add(time.Minute)}
+	roomStateRequests.Unlock()
+
+	invalidateRoomStateCache("ITB-1105")
+
+	roomStateRequests.Lock()
+	defer roomStateRequests.Unlock()
+
+	for key := range roomStateRequests.cache {
+		if strings.HasPrefix(key, "ITB-1105?") || key == "ITB-1105" {
+			t.Fatalf("expected %s to be invalidated", key)
+		}
+	}
+	if _, ok := roomStateRequests.cache["ITB-11050?fields=power"]; !ok {
+		t.Fatal("expected another room's cache to be kept")
+	}
+	delete(roomStateRequests.cache, "ITB-11050?fields=power")
+}
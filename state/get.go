@@ -102,7 +102,8 @@ func RunStatusCommandsWithContext(ctx context.Context, commands []se.StatusComma
 
 	for outputList := range channel {
 		for _, output := range outputList {
-			if output.ErrorMessage != nil {
+			// unreachable devices were already reported when their breaker opened
+			if output.ErrorMessage != nil && !deviceUnreachable(output.SourceDevice.ID) {
 				msg := fmt.Sprintf("problem querying status of device: %s with destination %s: %s", output.SourceDevice.Name, output.DestinationDevice.Name, *output.ErrorMessage)
 				log.L.Errorf("%s", color.HiRedString("[error] %s", msg))
 				cause := events.Error
@@ -5,8 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -17,6 +15,7 @@ import (
 	"github.com/byuoitav/av-api/base"
 	"github.com/byuoitav/av-api/internal/bearertoken"
 	se "github.com/byuoitav/av-api/statusevaluators"
+	"github.com/byuoitav/av-api/transport"
 	"github.com/byuoitav/common/log"
 	"github.com/byuoitav/common/v2/events"
 	"github.com/fatih/color"
@@ -95,19 +94,27 @@ func issueCommands(ctx context.Context, commands []se.StatusCommand, channel cha
 			continue
 		}
 
-		log.L.Infof("%s", color.HiBlueString("[state] sending request to %s", url))
-		timeout := time.Duration(TIMEOUT * time.Second)
-		client := http.Client{Timeout: timeout}
-		request, gerr := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-		if gerr != nil {
-			msg := fmt.Sprintf("unable to create request to %s for device %s: %s", url, command.Device.Name, gerr.Error())
-			log.L.Errorf("%s", color.HiRedString("[error] %s", msg))
+		if !breakerAllows(command.Device.ID) {
+			msg := errDeviceUnreachable(command.Device).Error()
+			log.L.Warnf("[state] %s", msg)
 			output.ErrorMessage = &msg
 			outputs = append(outputs, output)
 			continue
 		}
 
-		response, gerr := client.Do(request)
+		log.L.Infof("%s", color.HiBlueString("[state] sending request to %s", url))
+		sendStart := time.Now()
+		response, gerr := transport.Send(ctx, transport.Request{
+			Device:     command.Device,
+			Command:    command.Device.GetCommandByID(command.Action.ID),
+			URL:        url,
+			Parameters: command.Parameters,
+			Timeout:    TIMEOUT * time.Second,
+		})
+		if ctx.Err() == nil {
+			recordCommandResult(command.Device, response.StatusCode, gerr)
+			observeCommand(command.Device.ID, command.Action.ID, sendStart, response.StatusCode, gerr)
+		}
 		if gerr != nil {
 			msg := fmt.Sprintf("unable to complete request to %s for device %s: %s", url, command.Device.Name, gerr.Error())
 			log.L.Errorf("%s", color.HiRedString("[error] %s", msg))
@@ -116,19 +123,7 @@ func issueCommands(ctx context.Context, commands []se.StatusCommand, channel cha
 			continue
 		}
 
-		body, gerr := ioutil.ReadAll(io.LimitReader(response.Body, 1<<20))
-		closeErr := response.Body.Close()
-		if gerr != nil {
-			msg := fmt.Sprintf("unable to read response from %s for device %s: %s", url, command.Device.Name, gerr.Error())
-			log.L.Errorf("%s", color.HiRedString("[error] %s", msg))
-			output.ErrorMessage = &msg
-			outputs = append(outputs, output)
-			continue
-		}
-		if closeErr != nil {
-			msg := fmt.Sprintf("unable to close response from %s for device %s: %s", url, command.Device.Name, closeErr.Error())
-			log.L.Errorf("%s", color.HiRedString("[error] %s", msg))
-		}
+		body := response.Body
 
 		//check to see if it returned a non 200 response, if so, we need to build the error.
 		if response.StatusCode != 200 {
@@ -251,7 +246,7 @@ func processDisplay(device se.Status) (base.Display, error) {
 	return display, nil
 }
 
-// ExecuteCommand makes a GET request given a microservice and endpoint and publishes the results
+// ExecuteCommand sends a command given a microservice and endpoint and publishes the results
 // returns the state the microservice reports or nothing if the microservice doesn't respond
 // publishes a state event or an error
 // @pre the parameters have been filled, e.g. the endpoint does not contain ":"
@@ -259,30 +254,41 @@ func ExecuteCommand(action base.ActionStructure, url, requestor string) se.Statu
 	return ExecuteCommandWithContext(context.Background(), action, url, requestor)
 }
 
-// ExecuteCommandWithContext makes a GET request for a state-changing command and publishes the results.
+// ExecuteCommandWithContext sends a state-changing command over the command's transport and publishes the results.
 func ExecuteCommandWithContext(ctx context.Context, action base.ActionStructure, url, requestor string) se.StatusResponse {
-	client := &http.Client{
-		Timeout: TIMEOUT * time.Second,
-	}
+	trace := tracerFromContext(ctx)
+	start := time.Now()
 
-	log.L.Infof("%s", color.HiBlueString("[state] sending request to %s...", url))
+	if !breakerAllows(action.Device.ID) {
+		err := errDeviceUnreachable(action.Device)
+		trace.sent(action, url, start, transport.Response{}, err)
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
 		msg := err.Error()
+		log.L.Warnf("[state] %s", msg)
 		return se.StatusResponse{ErrorMessage: &msg}
 	}
 
+	log.L.Infof("%s", color.HiBlueString("[state] sending request to %s...", url))
+
+	header := http.Header{}
 	if len(os.Getenv("ROOM_SYSTEM")) == 0 {
 		//TODO: do new auth stuff .
 		token, err := bearertoken.GetToken()
 		if err != nil {
 			return se.StatusResponse{}
 		}
-		req.Header.Set("Authorization", "Bearer "+token.Token)
+		header.Set("Authorization", "Bearer "+token.Token)
 	}
 
-	resp, err := client.Do(req)
+	resp, err := sendWithRetry(ctx, action, transport.Request{
+		Device:     action.Device,
+		Command:    action.Device.GetCommandByID(action.Action),
+		URL:        url,
+		Parameters: action.Parameters,
+		Header:     header,
+		Timeout:    TIMEOUT * time.Second,
+	})
+	trace.sent(action, url, start, resp, err)
 	if err != nil { //record any errors
 		msg := fmt.Sprintf("error sending request: %s", err.Error())
 		log.L.Errorf("%s", color.HiRedString("[error] %s", msg))
@@ -290,19 +296,11 @@ func ExecuteCommandWithContext(ctx context.Context, action base.ActionStructure,
 		return se.StatusResponse{ErrorMessage: &msg}
 	}
 
-	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK { //check the response code, if non-200, we need to record and report
 
 		log.L.Errorf("%s", color.HiRedString("[error] non-200 response code: %v", resp.StatusCode))
-
-		b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
-		if err != nil {
-			log.L.Errorf("%s", color.HiRedString("[error] problem reading the response: %s", err.Error()))
-		}
-
-		log.L.Errorf("%s", color.HiRedString("[error] microservice returned: %s for action %s against device %s.", b, action.Action, action.Device.Name))
-		PublishError(fmt.Sprintf("%s", b), action, requestor)
+		log.L.Errorf("%s", color.HiRedString("[error] microservice returned: %s for action %s against device %s.", resp.Body, action.Action, action.Device.Name))
+		PublishError(fmt.Sprintf("%s", resp.Body), action, requestor)
 
 		return se.StatusResponse{}
 
@@ -311,19 +309,14 @@ func ExecuteCommandWithContext(ctx context.Context, action base.ActionStructure,
 	//TODO: we need to find some way to check against the correct response value, just as a further validation
 	for _, event := range action.EventLog {
 		base.SendEvent(event)
+		publishRoomStateEvent(event, action.DestinationDevice)
 	}
 
 	log.L.Infof("%s", color.HiGreenString("[state] sent command %s to device %s.", action.Action, action.Device.Name))
 	status := make(map[string]interface{})
-	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
-	if err != nil {
-		errorString := fmt.Sprintf("could not read response body: %s", err.Error())
-		PublishError(errorString, action, requestor)
-	}
-
-	err = json.Unmarshal(body, &status)
+	err = json.Unmarshal(resp.Body, &status)
 	if err != nil {
-		message := fmt.Sprintf("could not unmarshal response struct: %s\n\nbody: %s\n", err.Error(), body)
+		message := fmt.Sprintf("could not unmarshal response struct: %s\n\nbody: %s\n", err.Error(), resp.Body)
 		PublishError(message, action, requestor)
 	}
 	response := se.StatusResponse{
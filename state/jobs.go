@@ -0,0 +1,273 @@
+package state
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/common/log"
+)
+
+// Statuses of a room state job.
+const (
+	JobQueued     = "queued"
+	JobRunning    = "running"
+	JobSucceeded  = "succeeded"
+	JobFailed     = "failed"
+	JobSuperseded = "superseded"
+)
+
+// finishedJobRetention is how long a finished job can still be looked up.
+const finishedJobRetention = 10 * time.Minute
+
+var (
+	// ErrJobNotFound is returned when there is no room state job with the requested ID.
+	ErrJobNotFound = errors.New("no room state job with that id")
+
+	// ErrJobFinished is returned when cancelling a room state job that has already finished.
+	ErrJobFinished = errors.New("room state job has already finished")
+
+	// ErrJobMerged is returned when cancelling a room state job that was merged into the running job, whose change will
+	// still be made.
+	ErrJobMerged = errors.New("room state job has already been merged into running job")
+)
+
+// RoomStateJob is the status of a room state change run by a room's runner.
+type RoomStateJob struct {
+	ID        string           `json:"id"`
+	Building  string           `json:"building"`
+	Room      string           `json:"room"`
+	Requestor string           `json:"requestor"`
+	Status    string           `json:"status"`
+	Submitted time.Time        `json:"submitted"`
+	Started   *time.Time       `json:"started,omitempty"`
+	Finished  *time.Time       `json:"finished,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Report    *base.PublicRoom `json:"report,omitempty"`
+
+	// MergedInto is the ID of the newer job a superseded job was merged into.
+	MergedInto string `json:"mergedInto,omitempty"`
+}
+
+// setRoomStateJobs holds every job that is queued, running, or finished within finishedJobRetention. Its lock also
+// guards the status fields of each job.
+var setRoomStateJobs = struct {
+	sync.Mutex
+	jobs map[string]*setRoomStateJob
+}{
+	jobs: make(map[string]*setRoomStateJob),
+}
+
+// newSetRoomStateJob builds a job to change a room's state to target, carrying over the options in ctx, and registers
+// it so its status can be looked up.
+func newSetRoomStateJob(ctx context.Context, target base.PublicRoom, requestor string) *setRoomStateJob {
+	jobCtx := WithSetRoomStateOptions(context.Background(), setRoomStateOptionsFromContext(ctx))
+	jobCtx, cancel := context.WithTimeout(jobCtx, setRoomStateExecutionTimeout)
+
+	job := &setRoomStateJob{
+		id:        newJobID(),
+		ctx:       jobCtx,
+		cancel:    cancel,
+		building:  target.Building,
+		room:      target.Room,
+		target:    target,
+		requestor: requestor,
+		done:      make(chan setRoomStateResult, 1),
+		submitted: time.Now(),
+		status:    JobQueued,
+	}
+
+	setRoomStateJobs.Lock()
+	setRoomStateJobs.jobs[job.id] = job
+	setRoomStateJobs.Unlock()
+
+	return job
+}
+
+func newJobID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		// fall back to something unique enough to look the job up by
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// SubmitRoomState queues a change to the state of a room and returns without waiting for it to run. The options in
+// ctx are carried over to the job; ctx itself can end without affecting it.
+func SubmitRoomState(ctx context.Context, target base.PublicRoom, requestor string) RoomStateJob {
+	job := newSetRoomStateJob(ctx, target, requestor)
+	getSetRoomStateRunner(roomKey(target.Building, target.Room)).submit(job)
+
+	// nobody waits on an async job, so its context is released once it finishes
+	go func() {
+		<-job.done
+		job.cancel()
+	}()
+
+	log.L.Infof("[state] queued room state job %s for %s", job.id, roomKey(target.Building, target.Room))
+	return job.describe()
+}
+
+// GetRoomStateJob returns the status of a room state job.
+func GetRoomStateJob(id string) (RoomStateJob, error) {
+	setRoomStateJobs.Lock()
+	job, ok := setRoomStateJobs.jobs[id]
+	setRoomStateJobs.Unlock()
+
+	if !ok {
+		return RoomStateJob{}, ErrJobNotFound
+	}
+
+	return job.describe(), nil
+}
+
+// CancelRoomStateJob cancels a room state job. A queued job is removed from its room's queue; a running job has its
+// context cancelled. Either way, whoever is waiting on it gets ErrSuperseded. A job merged into the running job can't be
+// cancelled, since its change is still being made; it fails with ErrJobMerged and the job keeps waiting for the report.
+func CancelRoomStateJob(id string) (RoomStateJob, error) {
+	setRoomStateJobs.Lock()
+	job, ok := setRoomStateJobs.jobs[id]
+	setRoomStateJobs.Unlock()
+
+	if !ok {
+		return RoomStateJob{}, ErrJobNotFound
+	}
+
+	if described := job.describe(); described.Finished != nil {
+		return described, ErrJobFinished
+	}
+
+	key := roomKey(job.building, job.room)
+	runner := getSetRoomStateRunner(key)
+
+	runner.mu.Lock()
+	for i, queued := range runner.queued {
+		if queued != job {
+			continue
+		}
+
+		runner.queued = append(runner.queued[:i], runner.queued[i+1:]...)
+		setRoomStateQueueDepth.Set(float64(len(runner.queued)), key)
+		runner.mu.Unlock()
+
+		setRoomStateSuperseded.Inc(key)
+		job.cancel()
+		job.finish(base.PublicRoom{}, ErrSuperseded)
+
+		log.L.Infof("[state] removed room state job %s from the queue for %s", id, key)
+		return job.describe(), nil
+	}
+
+	active := runner.active == job
+	if !active && runner.active != nil {
+		for _, follower := range runner.active.followers {
+			if follower == job {
+				running := runner.active.id
+				runner.mu.Unlock()
+
+				return job.describe(), fmt.Errorf("%w %s", ErrJobMerged, running)
+			}
+		}
+	}
+	runner.mu.Unlock()
+
+	if !active {
+		return job.describe(), ErrJobFinished
+	}
+
+	job.cancel()
+
+	log.L.Infof("[state] cancelled running room state job %s for %s", id, key)
+	return job.describe(), nil
+}
+
+// start marks a job as running. A job that has already finished stays finished.
+func (j *setRoomStateJob) start() {
+	setRoomStateJobs.Lock()
+	defer setRoomStateJobs.Unlock()
+
+	if !j.ended.IsZero() {
+		return
+	}
+
+	j.started = time.Now()
+	j.status = JobRunning
+}
+
+// supersede marks a job as merged into the newer job with the given ID.
+func (j *setRoomStateJob) supersede(newer string) {
+	setRoomStateJobs.Lock()
+	defer setRoomStateJobs.Unlock()
+
+	j.mergedInto = newer
+}
+
+// record saves the outcome of a job and forgets it once finishedJobRetention has passed.
+func (j *setRoomStateJob) record(report base.PublicRoom, err error) {
+	setRoomStateJobs.Lock()
+	defer setRoomStateJobs.Unlock()
+
+	j.ended = time.Now()
+	switch {
+	case err == nil && len(j.mergedInto) > 0:
+		j.status = JobSuperseded
+		j.report = &report
+	case err == nil:
+		j.status = JobSucceeded
+		j.report = &report
+	case errors.Is(err, ErrSuperseded):
+		j.status = JobSuperseded
+		j.err = err
+	default:
+		j.status = JobFailed
+		j.err = err
+	}
+
+	if len(j.id) > 0 {
+		time.AfterFunc(finishedJobRetention, func() {
+			setRoomStateJobs.Lock()
+			delete(setRoomStateJobs.jobs, j.id)
+			setRoomStateJobs.Unlock()
+		})
+	}
+}
+
+func (j *setRoomStateJob) describe() RoomStateJob {
+	setRoomStateJobs.Lock()
+	defer setRoomStateJobs.Unlock()
+
+	job := RoomStateJob{
+		ID:         j.id,
+		Building:   j.building,
+		Room:       j.room,
+		Requestor:  j.requestor,
+		Status:     j.status,
+		Submitted:  j.submitted,
+		Report:     j.report,
+		MergedInto: j.mergedInto,
+	}
+
+	if !j.started.IsZero() {
+		started := j.started
+		job.Started = &started
+	}
+
+	if !j.ended.IsZero() {
+		ended := j.ended
+		job.Finished = &ended
+	}
+
+	if j.err != nil {
+		job.Error = j.err.Error()
+	}
+
+	return job
+}
@@ -0,0 +1,284 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+)
+
+// stubSetRoomState replaces setRoomStateWithContext with one that reports each request as it starts, and blocks requests
+// for the "slow" input until release is closed or their context is cancelled.
+func stubSetRoomState(t *testing.T) (started <-chan string, release chan struct{}) {
+	t.Helper()
+
+	original := setRoomStateWithContext
+	t.Cleanup(func() {
+		setRoomStateWithContext = original
+	})
+
+	starts := make(chan string, 8)
+	release = make(chan struct{})
+
+	setRoomStateWithContext = func(ctx context.Context, target base.PublicRoom, requestor string) (base.PublicRoom, error) {
+		starts <- target.CurrentVideoInput
+		if target.CurrentVideoInput == "slow" {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return base.PublicRoom{}, ctx.Err()
+			}
+		}
+
+		return target, nil
+	}
+
+	return starts, release
+}
+
+func waitForJobStatus(t *testing.T, id string, status string) RoomStateJob {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := GetRoomStateJob(id)
+		if err != nil {
+			t.Fatalf("unable to get job %s: %s", id, err)
+		}
+
+		if job.Status == status {
+			return job
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	job, _ := GetRoomStateJob(id)
+	t.Fatalf("timed out waiting for job %s to be %s; it is %s", id, status, job.Status)
+	return RoomStateJob{}
+}
+
+func TestSubmitRoomStateReportsJobProgress(t *testing.T) {
+	started, release := stubSetRoomState(t)
+
+	job := SubmitRoomState(context.Background(), base.PublicRoom{Building: "JOBS", Room: "1", CurrentVideoInput: "slow"}, "test")
+	if len(job.ID) == 0 || job.Building != "JOBS" || job.Room != "1" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	<-started
+	running := waitForJobStatus(t, job.ID, JobRunning)
+	if running.Started == nil || running.Finished != nil {
+		t.Fatalf("expected a started, unfinished job, got %+v", running)
+	}
+
+	close(release)
+	succeeded := waitForJobStatus(t, job.ID, JobSucceeded)
+	if succeeded.Finished == nil || succeeded.Report == nil || succeeded.Report.CurrentVideoInput != "slow" {
+		t.Fatalf("expected the final report with the job, got %+v", succeeded)
+	}
+
+	if _, err := CancelRoomStateJob(job.ID); !errors.Is(err, ErrJobFinished) {
+		t.Fatalf("expected cancelling a finished job to fail with ErrJobFinished, got %v", err)
+	}
+}
+
+func TestCancelRoomStateJob(t *testing.T) {
+	started, _ := stubSetRoomState(t)
+
+	active := SubmitRoomState(context.Background(), base.PublicRoom{Building: "JOBS", Room: "2", CurrentVideoInput: "slow"}, "test")
+	<-started
+
+	queued := SubmitRoomState(context.Background(), base.PublicRoom{Building: "JOBS", Room: "2", CurrentVideoInput: "queued"}, "test")
+	if queued.Status != JobQueued {
+		t.Fatalf("expected the second job to be queued, got %s", queued.Status)
+	}
+
+	cancelled, err := CancelRoomStateJob(queued.ID)
+	if err != nil {
+		t.Fatalf("unable to cancel queued job: %s", err)
+	}
+	if cancelled.Status != JobSuperseded {
+		t.Fatalf("expected the queued job to be superseded, got %+v", cancelled)
+	}
+
+	if _, err := CancelRoomStateJob(active.ID); err != nil {
+		t.Fatalf("unable to cancel running job: %s", err)
+	}
+
+	job := waitForJobStatus(t, active.ID, JobSuperseded)
+	if job.Error != ErrSuperseded.Error() {
+		t.Fatalf("expected the running job to end with ErrSuperseded, got %+v", job)
+	}
+
+	select {
+	case input := <-started:
+		t.Fatalf("expected the cancelled queued job not to run, but %q started", input)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if _, err := CancelRoomStateJob("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestCancelRoomStateJobWhileQueuedJobsAreMerged(t *testing.T) {
+	started, release := stubSetRoomState(t)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-started:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	SubmitRoomState(context.Background(), base.PublicRoom{Building: "CANCEL", Room: "1", CurrentVideoInput: "slow"}, "first")
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		job := SubmitRoomState(context.Background(), base.PublicRoom{Building: "CANCEL", Room: "1", Power: "on"}, "queued")
+		ids = append(ids, job.ID)
+	}
+
+	// cancelling reads the job's room while the runner merges the queue into the newest job
+	var wg sync.WaitGroup
+	ready := make(chan struct{})
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			<-ready
+
+			job, err := CancelRoomStateJob(id)
+			if err != nil && !errors.Is(err, ErrJobFinished) {
+				t.Errorf("unable to cancel %s: %s", id, err)
+			}
+			if job.Building != "CANCEL" || job.Room != "1" {
+				t.Errorf("unexpected room for %s: %+v", id, job)
+			}
+		}(id)
+	}
+
+	close(ready)
+	close(release)
+	wg.Wait()
+
+	for _, id := range ids {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			job, err := GetRoomStateJob(id)
+			if err != nil {
+				t.Fatalf("unable to get job %s: %s", id, err)
+			}
+			if job.Finished != nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s to finish, got %+v", id, job)
+			}
+
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+func TestCancelMergedRoomStateJobWhileItStarts(t *testing.T) {
+	started, release := stubSetRoomState(t)
+
+	SubmitRoomState(context.Background(), base.PublicRoom{Building: "MERGED", Room: "1", CurrentVideoInput: "slow"}, "first")
+	<-started
+
+	merged := WithSetRoomStateOptions(context.Background(), SetRoomStateOptions{MergedReport: true})
+	var ids []string
+	for i := 0; i < 5; i++ {
+		job := SubmitRoomState(merged, base.PublicRoom{Building: "MERGED", Room: "1", Power: "on"}, "follower")
+		ids = append(ids, job.ID)
+	}
+	newest := SubmitRoomState(context.Background(), base.PublicRoom{Building: "MERGED", Room: "1", CurrentVideoInput: "newest"}, "newest")
+
+	// the followers are cancelled while the runner merges them into the newest job and starts them
+	var wg sync.WaitGroup
+	ready := make(chan struct{})
+	errs := make([]error, len(ids))
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			<-ready
+
+			_, errs[i] = CancelRoomStateJob(id)
+		}(i, id)
+	}
+
+	close(ready)
+	close(release)
+	wg.Wait()
+	waitForJobStatus(t, newest.ID, JobSucceeded)
+
+	for i, id := range ids {
+		var job RoomStateJob
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			var err error
+			job, err = GetRoomStateJob(id)
+			if err != nil {
+				t.Fatalf("unable to get job %s: %s", id, err)
+			}
+			if job.Finished != nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s to finish, got %+v", id, job)
+			}
+
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		switch err := errs[i]; {
+		case errors.Is(err, ErrJobMerged):
+			// a follower that couldn't be cancelled still gets the merged report
+			if job.Status != JobSuperseded || job.Report == nil || job.Report.CurrentVideoInput != "newest" {
+				t.Fatalf("expected %s to get the merged report, got %+v", id, job)
+			}
+		case err == nil, errors.Is(err, ErrJobFinished):
+		default:
+			t.Fatalf("unable to cancel %s: %s", id, err)
+		}
+	}
+}
+
+func TestCancelMergedRoomStateJobLeavesItWaitingForTheReport(t *testing.T) {
+	started, release := stubSetRoomState(t)
+
+	SubmitRoomState(context.Background(), base.PublicRoom{Building: "MERGED", Room: "2", CurrentVideoInput: "slow"}, "first")
+	<-started
+
+	merged := WithSetRoomStateOptions(context.Background(), SetRoomStateOptions{MergedReport: true})
+	follower := SubmitRoomState(merged, base.PublicRoom{Building: "MERGED", Room: "2", Power: "on"}, "follower")
+	SubmitRoomState(context.Background(), base.PublicRoom{Building: "MERGED", Room: "2", CurrentVideoInput: "slow"}, "newest")
+
+	release <- struct{}{}
+	running := waitForJobStatus(t, follower.ID, JobRunning)
+	if len(running.MergedInto) == 0 {
+		t.Fatalf("expected the follower to be merged, got %+v", running)
+	}
+
+	_, err := CancelRoomStateJob(follower.ID)
+	if !errors.Is(err, ErrJobMerged) || !strings.HasSuffix(err.Error(), running.MergedInto) {
+		t.Fatalf("expected ErrJobMerged naming job %s, got %v", running.MergedInto, err)
+	}
+
+	close(release)
+	job := waitForJobStatus(t, follower.ID, JobSuperseded)
+	if job.Report == nil || job.Report.Power != "on" {
+		t.Fatalf("expected the follower's change to still be made, got %+v", job)
+	}
+}
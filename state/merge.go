@@ -0,0 +1,178 @@
+package state
+
+import (
+	"strings"
+
+	"github.com/byuoitav/av-api/base"
+	ce "github.com/byuoitav/av-api/commandevaluators"
+)
+
+// MergePublicRoom merges a newer room state request into an older one, field by field. Each field the newer request
+// sets replaces the older value: room-wide fields, and each field of a display or audio device, matched by name.
+// Relative values build on the older value instead, so two volumeDeltas add up and two toggles cancel out.
+// A room-wide field the newer request sets also drops the same field from the older request's devices, so the older
+// request can't override it for a single device, e.g. a newer room-wide volume replaces an older volume for D1.
+func MergePublicRoom(older base.PublicRoom, newer base.PublicRoom) base.PublicRoom {
+	merged := base.PublicRoom{
+		Building:          newer.Building,
+		Room:              newer.Room,
+		CurrentVideoInput: older.CurrentVideoInput,
+		CurrentAudioInput: older.CurrentAudioInput,
+	}
+
+	newerPower := len(newer.Power) > 0
+	newerBlanked := newer.Blanked != nil || newer.ToggleBlanked
+	newerMuted := newer.Muted != nil || newer.ToggleMuted
+	newerVolume := newer.Volume != nil || newer.VolumeDelta != nil
+
+	for _, display := range older.Displays {
+		if newerPower {
+			display.Power = ""
+		}
+		if len(newer.CurrentVideoInput) > 0 {
+			display.Input = ""
+		}
+		if newerBlanked {
+			display.Blanked, display.ToggleBlanked = nil, false
+		}
+
+		merged.Displays = append(merged.Displays, display)
+	}
+
+	for _, audioDevice := range older.AudioDevices {
+		if newerPower {
+			audioDevice.Power = ""
+		}
+		if len(newer.CurrentAudioInput) > 0 {
+			audioDevice.Input = ""
+		}
+		if newerMuted {
+			audioDevice.Muted, audioDevice.ToggleMuted = nil, false
+		}
+		if newerVolume {
+			audioDevice.Volume, audioDevice.VolumeDelta = nil, nil
+		}
+
+		merged.AudioDevices = append(merged.AudioDevices, audioDevice)
+	}
+
+	if len(newer.CurrentVideoInput) > 0 {
+		merged.CurrentVideoInput = newer.CurrentVideoInput
+	}
+	if len(newer.CurrentAudioInput) > 0 {
+		merged.CurrentAudioInput = newer.CurrentAudioInput
+	}
+
+	merged.Power = mergePower(older.Power, newer.Power)
+	merged.Blanked, merged.ToggleBlanked = mergeToggle(older.Blanked, older.ToggleBlanked, newer.Blanked, newer.ToggleBlanked)
+	merged.Muted, merged.ToggleMuted = mergeToggle(older.Muted, older.ToggleMuted, newer.Muted, newer.ToggleMuted)
+	merged.Volume, merged.VolumeDelta = mergeVolume(older.Volume, older.VolumeDelta, newer.Volume, newer.VolumeDelta)
+
+	for _, display := range newer.Displays {
+		i := indexOfDisplay(merged.Displays, display.Name)
+		if i == -1 {
+			merged.Displays = append(merged.Displays, display)
+			continue
+		}
+
+		old := &merged.Displays[i]
+		old.Power = mergePower(old.Power, display.Power)
+		if len(display.Input) > 0 {
+			old.Input = display.Input
+		}
+		old.Blanked, old.ToggleBlanked = mergeToggle(old.Blanked, old.ToggleBlanked, display.Blanked, display.ToggleBlanked)
+	}
+
+	for _, audioDevice := range newer.AudioDevices {
+		i := indexOfAudioDevice(merged.AudioDevices, audioDevice.Name)
+		if i == -1 {
+			merged.AudioDevices = append(merged.AudioDevices, audioDevice)
+			continue
+		}
+
+		old := &merged.AudioDevices[i]
+		old.Power = mergePower(old.Power, audioDevice.Power)
+		if len(audioDevice.Input) > 0 {
+			old.Input = audioDevice.Input
+		}
+		old.Muted, old.ToggleMuted = mergeToggle(old.Muted, old.ToggleMuted, audioDevice.Muted, audioDevice.ToggleMuted)
+		old.Volume, old.VolumeDelta = mergeVolume(old.Volume, old.VolumeDelta, audioDevice.Volume, audioDevice.VolumeDelta)
+	}
+
+	return merged
+}
+
+// mergePower returns the power a newer request leaves a device or room in, after an older one.
+func mergePower(older string, newer string) string {
+	if !strings.EqualFold(newer, base.Toggle) {
+		if len(newer) > 0 {
+			return newer
+		}
+
+		return older
+	}
+
+	switch {
+	case len(older) == 0:
+		return base.Toggle
+	case strings.EqualFold(older, base.Toggle):
+		return ""
+	case strings.EqualFold(older, "on"):
+		return "standby"
+	default:
+		return "on"
+	}
+}
+
+// mergeToggle returns the value and toggle of a field a newer request leaves after an older one.
+func mergeToggle(older *bool, olderToggle bool, newer *bool, newerToggle bool) (*bool, bool) {
+	switch {
+	case newer != nil:
+		return newer, false
+	case !newerToggle:
+		return older, olderToggle
+	case older != nil:
+		flipped := !*older
+		return &flipped, false
+	default:
+		return nil, !olderToggle
+	}
+}
+
+// mergeVolume returns the volume and volumeDelta a newer request leaves after an older one.
+func mergeVolume(older *int, olderDelta *int, newer *int, newerDelta *int) (*int, *int) {
+	switch {
+	case newer != nil:
+		return newer, nil
+	case newerDelta == nil:
+		return older, olderDelta
+	case older != nil:
+		volume := ce.ClampVolume(*older + *newerDelta)
+		return &volume, nil
+	case olderDelta != nil:
+		delta := *olderDelta + *newerDelta
+		return nil, &delta
+	default:
+		return nil, newerDelta
+	}
+}
+
+func indexOfDisplay(displays []base.Display, name string) int {
+	for i := range displays {
+		if displays[i].Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func indexOfAudioDevice(audioDevices []base.AudioDevice, name string) int {
+	for i := range audioDevices {
+		if audioDevices[i].Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,105 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/byuoitav/av-api/base"
+)
+
+func TestMergePublicRoomKeepsTheNewestValueOfEachField(t *testing.T) {
+	low, high := 10, 30
+	muted := true
+
+	older := base.PublicRoom{
+		Power:    "on",
+		Displays: []base.Display{{Device: base.Device{Name: "D1", Input: "HDMI1"}}},
+		AudioDevices: []base.AudioDevice{
+			{Device: base.Device{Name: "D1"}, Volume: &low, Muted: &muted},
+		},
+	}
+	newer := base.PublicRoom{
+		Building: "ITB",
+		Room:     "1101",
+		Volume:   &high,
+		Displays: []base.Display{
+			{Device: base.Device{Name: "D1", Power: "standby"}},
+			{Device: base.Device{Name: "D2", Input: "HDMI2"}},
+		},
+	}
+
+	merged := MergePublicRoom(older, newer)
+
+	if merged.Building != "ITB" || merged.Room != "1101" || merged.Power != "on" {
+		t.Fatalf("unexpected room-wide fields %+v", merged)
+	}
+	if merged.Volume == nil || *merged.Volume != high {
+		t.Fatalf("expected the newer room-wide volume, got %v", merged.Volume)
+	}
+
+	if len(merged.Displays) != 2 {
+		t.Fatalf("expected both displays, got %+v", merged.Displays)
+	}
+	if d1 := merged.Displays[0]; d1.Name != "D1" || d1.Input != "HDMI1" || d1.Power != "standby" {
+		t.Fatalf("expected D1 to keep its older input and take the newer power, got %+v", d1)
+	}
+	if d2 := merged.Displays[1]; d2.Name != "D2" || d2.Input != "HDMI2" {
+		t.Fatalf("unexpected D2 %+v", d2)
+	}
+
+	d1, ok := findAudioDevice(merged, "D1")
+	if !ok || d1.Volume != nil || d1.Muted == nil || !*d1.Muted {
+		t.Fatalf("expected the newer room-wide volume to replace D1's older volume but keep its mute, got %+v", d1)
+	}
+
+	if older.Displays[0].Power != "" || *older.AudioDevices[0].Volume != low {
+		t.Fatal("expected the older request not to be changed")
+	}
+}
+
+func TestMergePublicRoomCombinesRelativeFields(t *testing.T) {
+	up, down := 5, -2
+	volume := 40
+	muted := false
+
+	older := base.PublicRoom{
+		Power:         base.Toggle,
+		ToggleBlanked: true,
+		VolumeDelta:   &up,
+		AudioDevices: []base.AudioDevice{
+			{Device: base.Device{Name: "D1"}, Volume: &volume, Muted: &muted},
+		},
+	}
+	newer := base.PublicRoom{
+		Power:         base.Toggle,
+		ToggleBlanked: true,
+		VolumeDelta:   &down,
+	}
+
+	merged := MergePublicRoom(older, newer)
+
+	if merged.Power != "" || merged.ToggleBlanked || merged.Blanked != nil {
+		t.Fatalf("expected two toggles to cancel out, got %+v", merged)
+	}
+	if merged.VolumeDelta == nil || *merged.VolumeDelta != 3 {
+		t.Fatalf("expected the volumeDeltas to add up, got %v", merged.VolumeDelta)
+	}
+
+	d1, ok := findAudioDevice(merged, "D1")
+	if !ok || d1.Volume != nil || d1.Muted == nil {
+		t.Fatalf("expected the newer room-wide volumeDelta to replace D1's volume but keep its mute, got %+v", d1)
+	}
+
+	// a toggle or delta after an absolute value is applied to it
+	merged = MergePublicRoom(
+		base.PublicRoom{AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D1", Power: "on"}, Volume: &volume, Muted: &muted}}},
+		base.PublicRoom{AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D1", Power: base.Toggle}, VolumeDelta: &down, ToggleMuted: true}}},
+	)
+
+	d1 = merged.AudioDevices[0]
+	if d1.Power != "standby" || d1.Volume == nil || *d1.Volume != 38 || d1.VolumeDelta != nil {
+		t.Fatalf("unexpected D1 %+v", d1)
+	}
+	if d1.Muted == nil || !*d1.Muted || d1.ToggleMuted {
+		t.Fatalf("expected D1 to end up muted, got %+v", d1)
+	}
+}
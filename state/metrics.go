@@ -0,0 +1,36 @@
+package state
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/byuoitav/av-api/metrics"
+)
+
+var (
+	roomStatePhaseDuration = metrics.NewHistogramVec("av_api_room_state_phase_duration_seconds",
+		"Time spent in each phase of getting a room's state.", nil, "phase")
+
+	deviceCommandDuration = metrics.NewHistogramVec("av_api_device_command_duration_seconds",
+		"Time taken by each command sent to a device.", nil, "device", "command")
+
+	deviceCommandErrors = metrics.NewCounterVec("av_api_device_command_errors_total",
+		"Commands sent to a device that failed or returned a non-200 response.", "device", "command")
+
+	roomStateCacheRequests = metrics.NewCounterVec("av_api_room_state_cache_requests_total",
+		"Shared room state requests by result: hit (cached), shared (joined an in-flight request), or miss.", "result")
+
+	setRoomStateQueueDepth = metrics.NewGaugeVec("av_api_set_room_state_queue_depth",
+		"Room state changes waiting to run, per room.", "room")
+
+	setRoomStateSuperseded = metrics.NewCounterVec("av_api_set_room_state_superseded_total",
+		"Room state changes superseded by a newer request, per room.", "room")
+)
+
+// observeCommand records the latency and outcome of a command sent to a device.
+func observeCommand(deviceID string, command string, start time.Time, statusCode int, err error) {
+	deviceCommandDuration.Since(start, deviceID, command)
+	if err != nil || statusCode != http.StatusOK {
+		deviceCommandErrors.Inc(deviceID, command)
+	}
+}
@@ -0,0 +1,29 @@
+package state
+
+import "context"
+
+// SetRoomStateOptions are optional behaviors of a room state change.
+type SetRoomStateOptions struct {
+	// Verify reads back the state of each device touched by the change and reports fields that don't match the request.
+	Verify bool
+
+	// Trace returns a timeline of the change with the report.
+	Trace bool
+
+	// MergedReport returns the report of the merged change when a queued change is merged into a newer one, instead
+	// of ErrSuperseded.
+	MergedReport bool
+}
+
+type setRoomStateOptionsKey struct{}
+
+// WithSetRoomStateOptions returns a context that carries options for a room state change.
+// SetRoomStateLatest carries the options over to the job it runs.
+func WithSetRoomStateOptions(ctx context.Context, options SetRoomStateOptions) context.Context {
+	return context.WithValue(ctx, setRoomStateOptionsKey{}, options)
+}
+
+func setRoomStateOptionsFromContext(ctx context.Context) SetRoomStateOptions {
+	options, _ := ctx.Value(setRoomStateOptionsKey{}).(SetRoomStateOptions)
+	return options
+}
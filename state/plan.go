@@ -0,0 +1,88 @@
+package state
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/config"
+	"github.com/byuoitav/common/log"
+	"github.com/fatih/color"
+)
+
+// RoomStatePlan is the reconciled set of actions a room state change would execute.
+type RoomStatePlan struct {
+	Count   int             `json:"count"`
+	Actions []PlannedAction `json:"actions"`
+}
+
+// PlannedAction is a serializable view of a reconciled ActionStructure.
+type PlannedAction = base.PlannedAction
+
+// PlanRoomState generates and reconciles the actions for a room state change without executing them. Relative fields are
+// resolved against the room's current state, which is read if need be.
+func PlanRoomState(target base.PublicRoom, requestor string) (RoomStatePlan, error) {
+
+	log.L.Infof("%s", color.HiBlueString("[state] planning room state..."))
+
+	roomID := fmt.Sprintf("%v-%v", target.Building, target.Room)
+	room, err := config.GetRoom(roomID)
+	if err != nil {
+		return RoomStatePlan{}, err
+	}
+
+	target, err = resolveRelativeStateWithContext(context.Background(), target)
+	if err != nil {
+		return RoomStatePlan{}, err
+	}
+
+	actions, count, err := GenerateActions(room, target, requestor)
+	if err != nil {
+		return RoomStatePlan{}, err
+	}
+
+	return BuildRoomStatePlan(actions, count), nil
+}
+
+// BuildRoomStatePlan converts a reconciled DAG into a RoomStatePlan, rooted at the reconciler's start action.
+func BuildRoomStatePlan(DAG []base.ActionStructure, count int) RoomStatePlan {
+	plan := RoomStatePlan{
+		Count:   count,
+		Actions: []PlannedAction{},
+	}
+
+	if len(DAG) == 0 {
+		return plan
+	}
+
+	for _, child := range DAG[0].Children {
+		plan.Actions = append(plan.Actions, planAction(*child))
+	}
+
+	return plan
+}
+
+func planAction(action base.ActionStructure) PlannedAction {
+	planned := PlannedAction{
+		Action:              action.Action,
+		GeneratingEvaluator: action.GeneratingEvaluator,
+		Device:              action.Device.ID,
+		DestinationDevice:   action.DestinationDevice.ID,
+		Parameters:          action.Parameters,
+		DeviceSpecific:      action.DeviceSpecific,
+		Overridden:          action.Overridden,
+	}
+
+	url, err := buildActionURL(action)
+	if err != nil {
+		planned.URLError = err.Error()
+	} else {
+		planned.URL = url
+	}
+
+	for _, child := range action.Children {
+		planned.Children = append(planned.Children, planAction(*child))
+	}
+
+	return planned
+}
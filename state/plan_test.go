@@ -0,0 +1,57 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/common/structs"
+)
+
+func TestBuildRoomStatePlanResolvesURLsAndChildren(t *testing.T) {
+	device := structs.Device{
+		ID:      "ITB-1101-D1",
+		Name:    "D1",
+		Address: "10.0.0.1",
+		Type: structs.DeviceType{
+			Commands: []structs.Command{
+				statusCommand("PowerOn", "http://localhost:8005", "/:address/power/on"),
+				statusCommand("ChangeInput", "http://localhost:8005", "/:address/input/:port"),
+			},
+		},
+	}
+
+	input := base.ActionStructure{
+		Action:     "ChangeInput",
+		Device:     device,
+		Parameters: map[string]string{"port": "hdmi1"},
+	}
+	power := base.ActionStructure{
+		Action:   "PowerOn",
+		Device:   device,
+		Children: []*base.ActionStructure{&input},
+	}
+	DAG := []base.ActionStructure{
+		{Action: "Start", Overridden: true, Children: []*base.ActionStructure{&power}},
+		power,
+		input,
+	}
+
+	plan := BuildRoomStatePlan(DAG, 2)
+	if plan.Count != 2 {
+		t.Fatalf("expected count 2, got %d", plan.Count)
+	}
+	if len(plan.Actions) != 1 {
+		t.Fatalf("expected 1 root action, got %d", len(plan.Actions))
+	}
+
+	root := plan.Actions[0]
+	if root.URL != "http://localhost:8005/10.0.0.1/power/on" {
+		t.Fatalf("unexpected power url %q", root.URL)
+	}
+	if len(root.Children) != 1 {
+		t.Fatalf("expected 1 child action, got %d", len(root.Children))
+	}
+	if root.Children[0].URL != "http://localhost:8005/10.0.0.1/input/hdmi1" {
+		t.Fatalf("unexpected input url %q", root.Children[0].URL)
+	}
+}
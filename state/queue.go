@@ -0,0 +1,185 @@
+package state
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+)
+
+// RoomStateQueue is the room state changes a room's runner is working through.
+type RoomStateQueue struct {
+	Active *QueuedRoomState  `json:"active,omitempty"`
+	Queued []QueuedRoomState `json:"queued"`
+}
+
+// QueuedRoomState is a room state change that is running or waiting to run. Elapsed is the time since it was submitted.
+type QueuedRoomState struct {
+	ID        string    `json:"id"`
+	Requestor string    `json:"requestor"`
+	Submitted time.Time `json:"submitted"`
+	Elapsed   string    `json:"elapsed"`
+	Summary   string    `json:"summary"`
+}
+
+// GetRoomStateQueue returns the room state change a room is running and the ones waiting behind it, oldest first.
+func GetRoomStateQueue(building string, roomName string) RoomStateQueue {
+	runner := getSetRoomStateRunner(roomKey(building, roomName))
+	now := time.Now()
+
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	queue := RoomStateQueue{
+		Queued: []QueuedRoomState{},
+	}
+
+	if runner.active != nil {
+		active := runner.active.queued(now)
+		queue.Active = &active
+	}
+
+	for _, job := range runner.queued {
+		queue.Queued = append(queue.Queued, job.queued(now))
+	}
+
+	return queue
+}
+
+// CancelQueuedRoomState cancels a room state change that a room is running or has queued. See CancelRoomStateJob.
+func CancelQueuedRoomState(building string, roomName string, id string) (RoomStateJob, error) {
+	job, err := GetRoomStateJob(id)
+	if err != nil {
+		return RoomStateJob{}, err
+	}
+
+	if job.Building != building || job.Room != roomName {
+		return RoomStateJob{}, ErrJobNotFound
+	}
+
+	return CancelRoomStateJob(id)
+}
+
+func (j *setRoomStateJob) queued(now time.Time) QueuedRoomState {
+	return QueuedRoomState{
+		ID:        j.id,
+		Requestor: j.requestor,
+		Submitted: j.submitted,
+		Elapsed:   now.Sub(j.submitted).Round(time.Millisecond).String(),
+		Summary:   SummarizePublicRoom(j.target),
+	}
+}
+
+// SummarizePublicRoom describes the fields set in a room state request in one line, e.g.
+// "power=on input=HDMI1; D1: blanked=true; D2: volume=30".
+func SummarizePublicRoom(room base.PublicRoom) string {
+	var parts []string
+
+	fields := requestedFields{
+		power:         room.Power,
+		input:         room.CurrentVideoInput,
+		audioInput:    room.CurrentAudioInput,
+		blanked:       room.Blanked,
+		toggleBlanked: room.ToggleBlanked,
+		muted:         room.Muted,
+		toggleMuted:   room.ToggleMuted,
+		volume:        room.Volume,
+		volumeDelta:   room.VolumeDelta,
+	}.String()
+	if len(fields) > 0 {
+		parts = append(parts, fields)
+	}
+
+	// a device can be listed as both a display and an audio device, so its fields are gathered under one name
+	var names []string
+	devices := make(map[string][]string)
+	add := func(name string, fields string) {
+		if len(fields) == 0 {
+			return
+		}
+
+		if _, ok := devices[name]; !ok {
+			names = append(names, name)
+		}
+		devices[name] = append(devices[name], fields)
+	}
+
+	for _, display := range room.Displays {
+		add(display.Name, requestedFields{
+			power:         display.Power,
+			input:         display.Input,
+			blanked:       display.Blanked,
+			toggleBlanked: display.ToggleBlanked,
+		}.String())
+	}
+
+	for _, audioDevice := range room.AudioDevices {
+		add(audioDevice.Name, requestedFields{
+			power:       audioDevice.Power,
+			input:       audioDevice.Input,
+			muted:       audioDevice.Muted,
+			toggleMuted: audioDevice.ToggleMuted,
+			volume:      audioDevice.Volume,
+			volumeDelta: audioDevice.VolumeDelta,
+		}.String())
+	}
+
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s: %s", name, strings.Join(devices[name], " ")))
+	}
+
+	if len(parts) == 0 {
+		return "no changes"
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// requestedFields are the fields of a room or device in a room state request.
+type requestedFields struct {
+	power         string
+	input         string
+	audioInput    string
+	blanked       *bool
+	toggleBlanked bool
+	muted         *bool
+	toggleMuted   bool
+	volume        *int
+	volumeDelta   *int
+}
+
+func (f requestedFields) String() string {
+	var fields []string
+
+	if len(f.power) > 0 {
+		fields = append(fields, "power="+f.power)
+	}
+	if len(f.input) > 0 {
+		fields = append(fields, "input="+f.input)
+	}
+	if len(f.audioInput) > 0 {
+		fields = append(fields, "audioInput="+f.audioInput)
+	}
+	if f.blanked != nil {
+		fields = append(fields, "blanked="+strconv.FormatBool(*f.blanked))
+	}
+	if f.toggleBlanked {
+		fields = append(fields, "blanked="+base.Toggle)
+	}
+	if f.muted != nil {
+		fields = append(fields, "muted="+strconv.FormatBool(*f.muted))
+	}
+	if f.toggleMuted {
+		fields = append(fields, "muted="+base.Toggle)
+	}
+	if f.volume != nil {
+		fields = append(fields, "volume="+strconv.Itoa(*f.volume))
+	}
+	if f.volumeDelta != nil {
+		fields = append(fields, fmt.Sprintf("volumeDelta=%+d", *f.volumeDelta))
+	}
+
+	return strings.Join(fields, " ")
+}
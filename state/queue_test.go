@@ -0,0 +1,88 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+)
+
+func TestGetRoomStateQueueListsActiveAndQueuedRequests(t *testing.T) {
+	started, release := stubSetRoomState(t)
+	defer close(release)
+
+	active := SubmitRoomState(context.Background(), base.PublicRoom{Building: "QUEUE", Room: "1", CurrentVideoInput: "slow"}, "first")
+	<-started
+
+	// a caller waiting on its change gets ErrSuperseded when it's cancelled
+	result := make(chan error, 1)
+	go func() {
+		_, err := SetRoomStateLatest(context.Background(), base.PublicRoom{Building: "QUEUE", Room: "1", Power: "on"}, "second")
+		result <- err
+	}()
+
+	var queue RoomStateQueue
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if queue = GetRoomStateQueue("QUEUE", "1"); len(queue.Queued) == 1 {
+			break
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if queue.Active == nil || queue.Active.ID != active.ID || queue.Active.Requestor != "first" {
+		t.Fatalf("expected the first request to be active, got %+v", queue.Active)
+	}
+	if queue.Active.Summary != "input=slow" || len(queue.Active.Elapsed) == 0 {
+		t.Fatalf("unexpected active request %+v", queue.Active)
+	}
+	if len(queue.Queued) != 1 || queue.Queued[0].Requestor != "second" || queue.Queued[0].Summary != "power=on" {
+		t.Fatalf("expected the second request to be queued, got %+v", queue.Queued)
+	}
+
+	if _, err := CancelQueuedRoomState("QUEUE", "2", queue.Queued[0].ID); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected a request from another room not to be found, got %v", err)
+	}
+
+	if _, err := CancelQueuedRoomState("QUEUE", "1", queue.Queued[0].ID); err != nil {
+		t.Fatalf("unable to cancel the queued request: %s", err)
+	}
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrSuperseded) {
+			t.Fatalf("expected the waiting caller to get ErrSuperseded, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the cancelled request to return")
+	}
+
+	if queue := GetRoomStateQueue("QUEUE", "1"); len(queue.Queued) != 0 {
+		t.Fatalf("expected the queue to be empty, got %+v", queue.Queued)
+	}
+}
+
+func TestSummarizePublicRoom(t *testing.T) {
+	blanked := true
+	volume := 30
+
+	summary := SummarizePublicRoom(base.PublicRoom{
+		Power:    "on",
+		Displays: []base.Display{{Device: base.Device{Name: "D1", Input: "HDMI1"}, Blanked: &blanked}},
+		AudioDevices: []base.AudioDevice{
+			{Device: base.Device{Name: "D1"}, Volume: &volume},
+			{Device: base.Device{Name: "D2"}},
+		},
+	})
+
+	if want := "power=on; D1: input=HDMI1 blanked=true volume=30"; summary != want {
+		t.Fatalf("expected %q, got %q", want, summary)
+	}
+
+	if summary := SummarizePublicRoom(base.PublicRoom{}); summary != "no changes" {
+		t.Fatalf("expected an empty request to be summarized as no changes, got %q", summary)
+	}
+}
@@ -0,0 +1,318 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	ce "github.com/byuoitav/av-api/commandevaluators"
+	"github.com/byuoitav/common/log"
+	"github.com/fatih/color"
+)
+
+// relativeStateTimeout bounds a read of the fields relative fields depend on. The read isn't cached, since the change it
+// resolves makes it stale.
+const relativeStateTimeout = 30 * time.Second
+
+// HasRelativeState reports whether a room state request has fields that depend on the room's current state: a
+// volumeDelta, or power, blanked, or muted set to "toggle".
+func HasRelativeState(target base.PublicRoom) bool {
+	if target.VolumeDelta != nil || target.ToggleBlanked || target.ToggleMuted || strings.EqualFold(target.Power, base.Toggle) {
+		return true
+	}
+
+	for _, display := range target.Displays {
+		if display.ToggleBlanked || strings.EqualFold(display.Power, base.Toggle) {
+			return true
+		}
+	}
+
+	for _, audioDevice := range target.AudioDevices {
+		if audioDevice.VolumeDelta != nil || audioDevice.ToggleMuted || strings.EqualFold(audioDevice.Power, base.Toggle) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveRelativeStateWithContext resolves the relative fields of target against the room's current state. The current
+// state comes from the room's snapshot if it has one, and otherwise from a read of just the fields and devices needed,
+// which is shared with other reads of the room.
+func resolveRelativeStateWithContext(ctx context.Context, target base.PublicRoom) (base.PublicRoom, error) {
+	if !HasRelativeState(target) {
+		return target, nil
+	}
+
+	log.L.Infof("%s", color.HiBlueString("[state] resolving relative fields against the current state of %s...", roomKey(target.Building, target.Room)))
+
+	current, ok := GetRoomSnapshot(target.Building, target.Room)
+	if !ok {
+		var err error
+		current, err = GetRoomStateSharedWithFilter(ctx, target.Building, target.Room, relativeStateFilter(target), 0, relativeStateTimeout)
+		if err != nil {
+			return base.PublicRoom{}, fmt.Errorf("unable to read the current state of %s: %w", roomKey(target.Building, target.Room), err)
+		}
+	}
+
+	return ResolveRelativeState(current, target)
+}
+
+// relativeStateFilter selects the fields the relative fields of target depend on, on the devices they're set for. Any
+// relative room-wide field needs every device.
+func relativeStateFilter(target base.PublicRoom) StatusFilter {
+	fields := make(map[string]bool)
+	var devices []string
+	roomWide := false
+
+	if strings.EqualFold(target.Power, base.Toggle) {
+		fields["power"], roomWide = true, true
+	}
+	if target.ToggleBlanked {
+		fields["blanked"], roomWide = true, true
+	}
+	if target.ToggleMuted {
+		fields["muted"], roomWide = true, true
+	}
+	if target.VolumeDelta != nil {
+		fields["volume"], roomWide = true, true
+	}
+
+	for _, display := range target.Displays {
+		if strings.EqualFold(display.Power, base.Toggle) {
+			fields["power"] = true
+			devices = append(devices, display.Name)
+		}
+		if display.ToggleBlanked {
+			fields["blanked"] = true
+			devices = append(devices, display.Name)
+		}
+	}
+
+	for _, audioDevice := range target.AudioDevices {
+		if strings.EqualFold(audioDevice.Power, base.Toggle) {
+			fields["power"] = true
+			devices = append(devices, audioDevice.Name)
+		}
+		if audioDevice.ToggleMuted {
+			fields["muted"] = true
+			devices = append(devices, audioDevice.Name)
+		}
+		if audioDevice.VolumeDelta != nil {
+			fields["volume"] = true
+			devices = append(devices, audioDevice.Name)
+		}
+	}
+
+	var filter StatusFilter
+	for field := range fields {
+		filter.Fields = append(filter.Fields, field)
+	}
+
+	if !roomWide {
+		filter.Devices = devices
+	}
+
+	return filter
+}
+
+// ResolveRelativeState replaces the relative fields of a room state request with absolute values, based on current:
+//
+//   - power "toggle" turns a device off if it's on and on otherwise. Room-wide, the room counts as on if any device is.
+//   - blanked or muted "toggle" flips a device's current value. Room-wide, it unblanks or unmutes the room if every
+//     device is blanked or muted, and blanks or mutes it otherwise.
+//   - volumeDelta adds to a device's current volume, clamped to the levels the volume evaluators accept. Room-wide, it
+//     adds to each audio device's volume, unless the request sets that device's volume itself.
+func ResolveRelativeState(current base.PublicRoom, target base.PublicRoom) (base.PublicRoom, error) {
+	resolved := target
+	resolved.Displays = append([]base.Display(nil), target.Displays...)
+	resolved.AudioDevices = append([]base.AudioDevice(nil), target.AudioDevices...)
+
+	if target.Volume != nil && target.VolumeDelta != nil {
+		return base.PublicRoom{}, errors.New("volume and volumeDelta can't both be set")
+	}
+
+	for i := range resolved.Displays {
+		display := &resolved.Displays[i]
+
+		if strings.EqualFold(display.Power, base.Toggle) {
+			power, err := togglePower(current, display.Name)
+			if err != nil {
+				return base.PublicRoom{}, err
+			}
+
+			display.Power = power
+		}
+
+		if display.ToggleBlanked {
+			old, ok := findDisplay(current, display.Name)
+			if !ok || old.Blanked == nil {
+				return base.PublicRoom{}, fmt.Errorf("unable to toggle blanked on %s: its current state is unknown", display.Name)
+			}
+
+			blanked := !*old.Blanked
+			display.Blanked = &blanked
+			display.ToggleBlanked = false
+		}
+	}
+
+	for i := range resolved.AudioDevices {
+		audioDevice := &resolved.AudioDevices[i]
+
+		if audioDevice.Volume != nil && audioDevice.VolumeDelta != nil {
+			return base.PublicRoom{}, fmt.Errorf("volume and volumeDelta can't both be set for %s", audioDevice.Name)
+		}
+
+		if strings.EqualFold(audioDevice.Power, base.Toggle) {
+			power, err := togglePower(current, audioDevice.Name)
+			if err != nil {
+				return base.PublicRoom{}, err
+			}
+
+			audioDevice.Power = power
+		}
+
+		old, ok := findAudioDevice(current, audioDevice.Name)
+
+		if audioDevice.ToggleMuted {
+			if !ok || old.Muted == nil {
+				return base.PublicRoom{}, fmt.Errorf("unable to toggle muted on %s: its current state is unknown", audioDevice.Name)
+			}
+
+			muted := !*old.Muted
+			audioDevice.Muted = &muted
+			audioDevice.ToggleMuted = false
+		}
+
+		if audioDevice.VolumeDelta != nil {
+			if !ok || old.Volume == nil {
+				return base.PublicRoom{}, fmt.Errorf("unable to change the volume of %s: its current volume is unknown", audioDevice.Name)
+			}
+
+			volume := ce.ClampVolume(*old.Volume + *audioDevice.VolumeDelta)
+			audioDevice.Volume = &volume
+			audioDevice.VolumeDelta = nil
+		}
+	}
+
+	if strings.EqualFold(resolved.Power, base.Toggle) {
+		resolved.Power = "on"
+		if roomIsOn(current) {
+			resolved.Power = "standby"
+		}
+	}
+
+	if resolved.ToggleBlanked {
+		blanked := true
+		if everyDisplay(current, func(display base.Display) bool { return display.Blanked != nil && *display.Blanked }) {
+			blanked = false
+		}
+
+		resolved.Blanked = &blanked
+		resolved.ToggleBlanked = false
+	}
+
+	if resolved.ToggleMuted {
+		muted := true
+		if everyAudioDevice(current, func(audioDevice base.AudioDevice) bool { return audioDevice.Muted != nil && *audioDevice.Muted }) {
+			muted = false
+		}
+
+		resolved.Muted = &muted
+		resolved.ToggleMuted = false
+	}
+
+	if resolved.VolumeDelta != nil {
+		changed := false
+		for _, old := range current.AudioDevices {
+			if old.Volume == nil {
+				continue
+			}
+
+			i := indexOfAudioDevice(resolved.AudioDevices, old.Name)
+			if i == -1 {
+				resolved.AudioDevices = append(resolved.AudioDevices, base.AudioDevice{Device: base.Device{Name: old.Name}})
+				i = len(resolved.AudioDevices) - 1
+			}
+
+			// a volume set for the device itself wins over the room-wide change
+			if resolved.AudioDevices[i].Volume != nil {
+				continue
+			}
+
+			volume := ce.ClampVolume(*old.Volume + *resolved.VolumeDelta)
+			resolved.AudioDevices[i].Volume = &volume
+			changed = true
+		}
+
+		if !changed {
+			return base.PublicRoom{}, errors.New("unable to change the room's volume: the current volume of its audio devices is unknown")
+		}
+
+		resolved.VolumeDelta = nil
+	}
+
+	return resolved, nil
+}
+
+// togglePower returns the opposite of a device's current power, looking at it as a display and then as an audio device.
+func togglePower(current base.PublicRoom, name string) (string, error) {
+	power := ""
+	if display, ok := findDisplay(current, name); ok {
+		power = display.Power
+	}
+	if audioDevice, ok := findAudioDevice(current, name); ok && len(power) == 0 {
+		power = audioDevice.Power
+	}
+
+	if len(power) == 0 {
+		return "", fmt.Errorf("unable to toggle the power of %s: its current state is unknown", name)
+	}
+
+	if strings.EqualFold(power, "on") {
+		return "standby", nil
+	}
+
+	return "on", nil
+}
+
+func roomIsOn(current base.PublicRoom) bool {
+	for _, display := range current.Displays {
+		if strings.EqualFold(display.Power, "on") {
+			return true
+		}
+	}
+
+	for _, audioDevice := range current.AudioDevices {
+		if strings.EqualFold(audioDevice.Power, "on") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// everyDisplay reports whether there are displays in current and ok is true for each of them.
+func everyDisplay(current base.PublicRoom, ok func(base.Display) bool) bool {
+	for _, display := range current.Displays {
+		if !ok(display) {
+			return false
+		}
+	}
+
+	return len(current.Displays) > 0
+}
+
+// everyAudioDevice reports whether there are audio devices in current and ok is true for each of them.
+func everyAudioDevice(current base.PublicRoom, ok func(base.AudioDevice) bool) bool {
+	for _, audioDevice := range current.AudioDevices {
+		if !ok(audioDevice) {
+			return false
+		}
+	}
+
+	return len(current.AudioDevices) > 0
+}
@@ -0,0 +1,119 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/byuoitav/av-api/base"
+)
+
+func TestResolveRelativeStateResolvesDeviceFields(t *testing.T) {
+	blanked, muted := true, false
+	volume := 95
+	delta := 10
+
+	current := base.PublicRoom{
+		Displays:     []base.Display{{Device: base.Device{Name: "D1", Power: "on"}, Blanked: &blanked}},
+		AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D1", Power: "on"}, Muted: &muted, Volume: &volume}},
+	}
+	target := base.PublicRoom{
+		Displays:     []base.Display{{Device: base.Device{Name: "D1", Power: base.Toggle}, ToggleBlanked: true}},
+		AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D1"}, ToggleMuted: true, VolumeDelta: &delta}},
+	}
+
+	resolved, err := ResolveRelativeState(current, target)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if HasRelativeState(resolved) {
+		t.Fatalf("expected every relative field to be resolved, got %+v", resolved)
+	}
+
+	d1 := resolved.Displays[0]
+	if d1.Power != "standby" || d1.Blanked == nil || *d1.Blanked {
+		t.Fatalf("expected D1 to be turned off and unblanked, got %+v", d1)
+	}
+
+	a1 := resolved.AudioDevices[0]
+	if a1.Muted == nil || !*a1.Muted {
+		t.Fatalf("expected D1 to be muted, got %+v", a1)
+	}
+	if a1.Volume == nil || *a1.Volume != 100 {
+		t.Fatalf("expected D1's volume to be clamped to 100, got %v", a1.Volume)
+	}
+
+	if !target.Displays[0].ToggleBlanked || target.AudioDevices[0].VolumeDelta == nil {
+		t.Fatal("expected the request not to be changed")
+	}
+}
+
+func TestResolveRelativeStateResolvesRoomWideFields(t *testing.T) {
+	blanked := true
+	low, high := 5, 40
+	set := 70
+	delta := -10
+
+	current := base.PublicRoom{
+		Displays: []base.Display{
+			{Device: base.Device{Name: "D1", Power: "standby"}, Blanked: &blanked},
+			{Device: base.Device{Name: "D2", Power: "standby"}, Blanked: &blanked},
+		},
+		AudioDevices: []base.AudioDevice{
+			{Device: base.Device{Name: "D1"}, Volume: &low},
+			{Device: base.Device{Name: "D2"}, Volume: &high},
+			{Device: base.Device{Name: "MIC1"}, Volume: &high},
+		},
+	}
+	target := base.PublicRoom{
+		Power:         base.Toggle,
+		ToggleBlanked: true,
+		VolumeDelta:   &delta,
+		AudioDevices:  []base.AudioDevice{{Device: base.Device{Name: "MIC1"}, Volume: &set}},
+	}
+
+	resolved, err := ResolveRelativeState(current, target)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if resolved.Power != "on" {
+		t.Fatalf("expected a room that's off to be turned on, got %q", resolved.Power)
+	}
+	if resolved.Blanked == nil || *resolved.Blanked {
+		t.Fatalf("expected a room that's all blanked to be unblanked, got %v", resolved.Blanked)
+	}
+	if resolved.VolumeDelta != nil {
+		t.Fatalf("expected the room-wide volumeDelta to be resolved, got %v", *resolved.VolumeDelta)
+	}
+
+	expected := map[string]int{"D1": 0, "D2": 30, "MIC1": set}
+	for name, volume := range expected {
+		audioDevice, ok := findAudioDevice(resolved, name)
+		if !ok || audioDevice.Volume == nil || *audioDevice.Volume != volume {
+			t.Fatalf("expected %s's volume to be %d, got %+v", name, volume, audioDevice)
+		}
+	}
+}
+
+func TestResolveRelativeStateErrors(t *testing.T) {
+	volume, delta := 30, 5
+
+	tests := map[string]base.PublicRoom{
+		"volume and volumeDelta": {Volume: &volume, VolumeDelta: &delta},
+		"unknown power":          {Displays: []base.Display{{Device: base.Device{Name: "D1", Power: base.Toggle}}}},
+		"unknown blanked":        {Displays: []base.Display{{Device: base.Device{Name: "D1"}, ToggleBlanked: true}}},
+		"unknown volume":         {AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D1"}, VolumeDelta: &delta}}},
+		"unknown room volume":    {VolumeDelta: &delta},
+	}
+
+	current := base.PublicRoom{
+		Displays:     []base.Display{{Device: base.Device{Name: "D1"}}},
+		AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D1"}}},
+	}
+
+	for name, target := range tests {
+		if _, err := ResolveRelativeState(current, target); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/common/log"
+	"github.com/fatih/color"
 )
 
 var ErrSuperseded = errors.New("room state request superseded by a newer request")
@@ -38,20 +41,29 @@ var roomStateRequests = struct {
 }
 
 func GetRoomStateShared(ctx context.Context, building string, roomName string, cacheTTL time.Duration, timeout time.Duration) (base.PublicRoom, error) {
-	key := roomKey(building, roomName)
+	return GetRoomStateSharedWithFilter(ctx, building, roomName, StatusFilter{}, cacheTTL, timeout)
+}
+
+// GetRoomStateSharedWithFilter is GetRoomStateShared for part of a room. Reads with the same filter share results.
+func GetRoomStateSharedWithFilter(ctx context.Context, building string, roomName string, filter StatusFilter, cacheTTL time.Duration, timeout time.Duration) (base.PublicRoom, error) {
+	key := roomKey(building, roomName) + filter.String()
 	now := time.Now()
 
 	roomStateRequests.Lock()
 	if cached, ok := roomStateRequests.cache[key]; ok && now.Before(cached.expiresAt) {
 		roomStateRequests.Unlock()
+		roomStateCacheRequests.Inc("hit")
 		return cached.status, cached.err
 	}
 
 	if running, ok := roomStateRequests.running[key]; ok {
 		roomStateRequests.Unlock()
+		roomStateCacheRequests.Inc("shared")
 		return waitForRoomState(ctx, running)
 	}
 
+	roomStateCacheRequests.Inc("miss")
+
 	running := &roomStateInflight{
 		done: make(chan struct{}),
 	}
@@ -62,7 +74,7 @@ func GetRoomStateShared(ctx context.Context, building string, roomName string, c
 		runCtx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
 
-		status, err := GetRoomStateWithContext(runCtx, building, roomName)
+		status, err := GetRoomStateWithFilter(runCtx, building, roomName, filter)
 
 		roomStateRequests.Lock()
 		running.status = status
@@ -97,16 +109,36 @@ type setRoomStateResult struct {
 }
 
 type setRoomStateJob struct {
-	ctx       context.Context
-	cancel    context.CancelFunc
-	target    base.PublicRoom
+	id     string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// building and room don't change, so they can be read without a lock; target is replaced when queued jobs are
+	// merged into it, guarded by the runner's lock and setRoomStateJobs
+	building string
+	room     string
+	target   base.PublicRoom
+
 	requestor string
+	submitted time.Time
 	done      chan setRoomStateResult
 	once      sync.Once
+
+	// followers are older jobs merged into this one that wait for its report, guarded by the runner's lock
+	followers []*setRoomStateJob
+
+	// status fields, guarded by setRoomStateJobs
+	status     string
+	started    time.Time
+	ended      time.Time
+	report     *base.PublicRoom
+	err        error
+	mergedInto string
 }
 
 func (j *setRoomStateJob) finish(status base.PublicRoom, err error) {
 	j.once.Do(func() {
+		j.record(status, err)
 		j.done <- setRoomStateResult{status: status, err: err}
 		close(j.done)
 	})
@@ -127,23 +159,14 @@ var setRoomStateRequests = struct {
 }
 
 func SetRoomStateLatest(ctx context.Context, target base.PublicRoom, requestor string) (base.PublicRoom, error) {
-	key := roomKey(target.Building, target.Room)
-	runner := getSetRoomStateRunner(key)
-
-	jobCtx, cancel := context.WithTimeout(context.Background(), setRoomStateExecutionTimeout)
-	job := &setRoomStateJob{
-		ctx:       jobCtx,
-		cancel:    cancel,
-		target:    target,
-		requestor: requestor,
-		done:      make(chan setRoomStateResult, 1),
-	}
+	runner := getSetRoomStateRunner(roomKey(target.Building, target.Room))
 
+	job := newSetRoomStateJob(ctx, target, requestor)
 	runner.submit(job)
 
 	select {
 	case result := <-job.done:
-		cancel()
+		job.cancel()
 		return result.status, result.err
 	case <-ctx.Done():
 		return base.PublicRoom{}, ctx.Err()
@@ -167,9 +190,10 @@ func (r *setRoomStateRunner) submit(job *setRoomStateJob) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	invalidateRoomStateCache(roomKey(job.target.Building, job.target.Room))
+	invalidateRoomStateCache(roomKey(job.building, job.room))
 
 	r.queued = append(r.queued, job)
+	setRoomStateQueueDepth.Set(float64(len(r.queued)), roomKey(job.building, job.room))
 	if !r.running {
 		r.running = true
 		go r.run()
@@ -179,32 +203,85 @@ func (r *setRoomStateRunner) submit(job *setRoomStateJob) {
 func (r *setRoomStateRunner) run() {
 	for {
 		r.mu.Lock()
-		var job *setRoomStateJob
-		if len(r.queued) > 0 {
-			job = r.queued[0]
-			r.queued[0] = nil
-			r.queued = r.queued[1:]
-		}
-		r.active = job
-		if job == nil {
+		if len(r.queued) == 0 {
 			r.active = nil
 			r.running = false
 			r.mu.Unlock()
 			return
 		}
+
+		// everything that queued up while the last change ran is merged into the newest request
+		queued := r.queued
+		r.queued = nil
+
+		job := queued[len(queued)-1]
+		key := roomKey(job.building, job.room)
+		setRoomStateQueueDepth.Set(0, key)
+
+		var superseded []*setRoomStateJob
+		target := queued[0].target
+		for _, newer := range queued[1:] {
+			target = MergePublicRoom(target, newer.target)
+		}
+
+		for _, older := range queued[:len(queued)-1] {
+			older.supersede(job.id)
+			if setRoomStateOptionsFromContext(older.ctx).MergedReport {
+				job.followers = append(job.followers, older)
+			} else {
+				superseded = append(superseded, older)
+			}
+		}
+
+		setRoomStateJobs.Lock()
+		job.target = target
+		setRoomStateJobs.Unlock()
+
+		followers := append([]*setRoomStateJob(nil), job.followers...)
+		r.active = job
 		r.mu.Unlock()
 
-		status, err := setRoomStateWithContext(job.ctx, job.target, job.requestor)
+		if len(queued) > 1 {
+			log.L.Infof("%s", color.HiBlueString("[state] merged %d queued room state changes for %s", len(queued), key))
+		}
+
+		for _, older := range superseded {
+			setRoomStateSuperseded.Inc(key)
+			older.finish(base.PublicRoom{}, ErrSuperseded)
+		}
+
+		job.start()
+		for _, follower := range followers {
+			follower.start()
+		}
+
+		noteRoomStateSet(key, false)
+
+		status, err := setRoomStateWithContext(job.ctx, target, job.requestor)
+		noteRoomStateSet(key, err == nil)
 		if errors.Is(err, context.Canceled) {
 			err = ErrSuperseded
+			setRoomStateSuperseded.Inc(key)
+		}
+		if err == nil {
+			// reads started before the change finished are stale now
+			applyRoomStateSet(key, status)
+			invalidateRoomStateCache(key)
+			publishRoomStateChange(key, UpdateSourceSet, status)
 		}
 		job.finish(status, err)
 
 		r.mu.Lock()
+		followers = job.followers
+		job.followers = nil
 		if r.active == job {
 			r.active = nil
 		}
 		r.mu.Unlock()
+
+		for _, follower := range followers {
+			follower.finish(status, err)
+		}
 	}
 }
 
@@ -212,8 +289,13 @@ func roomKey(building string, roomName string) string {
 	return fmt.Sprintf("%s-%s", building, roomName)
 }
 
+// invalidateRoomStateCache drops a room's cached state, including every filtered read of it.
 func invalidateRoomStateCache(key string) {
 	roomStateRequests.Lock()
-	delete(roomStateRequests.cache, key)
+	for cached := range roomStateRequests.cache {
+		if cached == key || strings.HasPrefix(cached, key+"?") {
+			delete(roomStateRequests.cache, cached)
+		}
+	}
 	roomStateRequests.Unlock()
 }
@@ -2,7 +2,7 @@ package state
 
 import (
 	"context"
-	"reflect"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -10,7 +10,7 @@ import (
 	"github.com/byuoitav/av-api/base"
 )
 
-func TestSetRoomStateRunnerExecutesQueuedRequestsInOrder(t *testing.T) {
+func TestSetRoomStateRunnerMergesRequestsQueuedBehindTheActiveOne(t *testing.T) {
 	originalSetRoomStateWithContext := setRoomStateWithContext
 	defer func() {
 		setRoomStateWithContext = originalSetRoomStateWithContext
@@ -19,7 +19,7 @@ func TestSetRoomStateRunnerExecutesQueuedRequestsInOrder(t *testing.T) {
 	started := make(chan string, 3)
 	releaseFirst := make(chan struct{})
 	var mu sync.Mutex
-	var executed []string
+	var executed []base.PublicRoom
 
 	setRoomStateWithContext = func(ctx context.Context, target base.PublicRoom, requestor string) (base.PublicRoom, error) {
 		started <- target.CurrentVideoInput
@@ -28,7 +28,7 @@ func TestSetRoomStateRunnerExecutesQueuedRequestsInOrder(t *testing.T) {
 		}
 
 		mu.Lock()
-		executed = append(executed, target.CurrentVideoInput)
+		executed = append(executed, target)
 		mu.Unlock()
 
 		return target, nil
@@ -37,6 +37,7 @@ func TestSetRoomStateRunnerExecutesQueuedRequestsInOrder(t *testing.T) {
 	runner := &setRoomStateRunner{}
 	first := newSetRoomStateTestJob("first")
 	second := newSetRoomStateTestJob("second")
+	second.target.Power = "on"
 	third := newSetRoomStateTestJob("third")
 
 	runner.submit(first)
@@ -49,22 +50,57 @@ func TestSetRoomStateRunnerExecutesQueuedRequestsInOrder(t *testing.T) {
 	close(releaseFirst)
 
 	waitForSetRoomStateTestJob(t, first)
-	waitForSetRoomStateTestJob(t, second)
-	waitForSetRoomStateTestJob(t, third)
+	if result := waitForSetRoomStateTestJob(t, second); !errors.Is(result.err, ErrSuperseded) {
+		t.Fatalf("expected the merged request to be superseded, got %v", result.err)
+	}
+	if result := waitForSetRoomStateTestJob(t, third); result.err != nil {
+		t.Fatalf("expected the newest request to succeed, got %v", result.err)
+	}
 
 	mu.Lock()
 	defer mu.Unlock()
-	want := []string{"first", "second", "third"}
-	if !reflect.DeepEqual(executed, want) {
-		t.Fatalf("expected queued requests to execute in order %v, got %v", want, executed)
+	if len(executed) != 2 {
+		t.Fatalf("expected the queued requests to be merged into one, got %+v", executed)
+	}
+	if merged := executed[1]; merged.CurrentVideoInput != "third" || merged.Power != "on" {
+		t.Fatalf("expected the newest input and the older power in the merged request, got %+v", merged)
+	}
+}
+
+func TestSetRoomStateRunnerReturnsMergedReportWhenAsked(t *testing.T) {
+	started, release := stubSetRoomState(t)
+
+	active := newSetRoomStateTestJob("slow")
+	older := newSetRoomStateTestJob("older")
+	older.ctx = WithSetRoomStateOptions(older.ctx, SetRoomStateOptions{MergedReport: true})
+	newer := newSetRoomStateTestJob("newer")
+
+	runner := &setRoomStateRunner{}
+	runner.submit(active)
+	<-started
+
+	runner.submit(older)
+	runner.submit(newer)
+	close(release)
+
+	result := waitForSetRoomStateTestJob(t, older)
+	if result.err != nil || result.status.CurrentVideoInput != "newer" {
+		t.Fatalf("expected the merged report, got %+v, %v", result.status, result.err)
+	}
+
+	if status := older.describe(); status.Status != JobSuperseded || status.Report == nil {
+		t.Fatalf("expected the older job to be superseded with the merged report, got %+v", status)
 	}
 }
 
 func newSetRoomStateTestJob(input string) *setRoomStateJob {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &setRoomStateJob{
+		id:        input,
 		ctx:       ctx,
 		cancel:    cancel,
+		building:  "B",
+		room:      "R",
 		target:    base.PublicRoom{Building: "B", Room: "R", CurrentVideoInput: input},
 		requestor: "test",
 		done:      make(chan setRoomStateResult, 1),
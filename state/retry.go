@@ -0,0 +1,202 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/transport"
+	"github.com/byuoitav/common/log"
+	"github.com/fatih/color"
+)
+
+// DefaultRetryableStatusCodes are the response codes retried when a policy doesn't list its own.
+var DefaultRetryableStatusCodes = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// DefaultInitialBackoff is the wait before the first retry when a policy doesn't set a positive initialBackoff, so a
+// failing device isn't retried right away.
+const DefaultInitialBackoff = 250 * time.Millisecond
+
+// RetryPolicy controls how a state-changing command is retried when it fails.
+// Transport errors are always retried; responses are retried if their status code is retryable.
+type RetryPolicy struct {
+	MaxAttempts          int           `json:"maxAttempts"`
+	InitialBackoff       time.Duration `json:"-"`
+	MaxBackoff           time.Duration `json:"-"`
+	Multiplier           float64       `json:"multiplier,omitempty"`
+	RetryableStatusCodes []int         `json:"retryableStatusCodes,omitempty"`
+}
+
+// UnmarshalJSON reads backoffs as duration strings, e.g. "250ms". A missing initialBackoff is DefaultInitialBackoff.
+func (p *RetryPolicy) UnmarshalJSON(b []byte) error {
+	type policy RetryPolicy
+	aux := struct {
+		*policy
+		InitialBackoff string `json:"initialBackoff"`
+		MaxBackoff     string `json:"maxBackoff"`
+	}{
+		policy: (*policy)(p),
+	}
+
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	var err error
+	if len(aux.InitialBackoff) > 0 {
+		if p.InitialBackoff, err = time.ParseDuration(aux.InitialBackoff); err != nil {
+			return fmt.Errorf("invalid initialBackoff: %w", err)
+		}
+	}
+	if len(aux.MaxBackoff) > 0 {
+		if p.MaxBackoff, err = time.ParseDuration(aux.MaxBackoff); err != nil {
+			return fmt.Errorf("invalid maxBackoff: %w", err)
+		}
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = DefaultInitialBackoff
+	}
+
+	return nil
+}
+
+// RetryPolicies holds the default retry policy and its overrides. A command's policy wins over its device type's.
+type RetryPolicies struct {
+	Default     RetryPolicy            `json:"default"`
+	Commands    map[string]RetryPolicy `json:"commands,omitempty"`
+	DeviceTypes map[string]RetryPolicy `json:"deviceTypes,omitempty"`
+}
+
+var retryPolicies = struct {
+	sync.RWMutex
+	policies RetryPolicies
+}{
+	policies: RetryPolicies{
+		Default: RetryPolicy{MaxAttempts: 1},
+	},
+}
+
+// SetRetryPolicies replaces the retry policies used for state-changing commands.
+func SetRetryPolicies(policies RetryPolicies) {
+	retryPolicies.Lock()
+	defer retryPolicies.Unlock()
+
+	retryPolicies.policies = policies
+}
+
+// LoadRetryPolicies reads retry policies from a JSON file and starts using them.
+func LoadRetryPolicies(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read retry policies: %w", err)
+	}
+
+	var policies RetryPolicies
+	if err := json.Unmarshal(b, &policies); err != nil {
+		return fmt.Errorf("unable to parse retry policies: %w", err)
+	}
+
+	SetRetryPolicies(policies)
+	log.L.Infof("[state] loaded retry policies from %s", path)
+	return nil
+}
+
+func retryPolicyFor(action base.ActionStructure) RetryPolicy {
+	retryPolicies.RLock()
+	defer retryPolicies.RUnlock()
+
+	policy, ok := retryPolicies.policies.Commands[action.Action]
+	if !ok {
+		policy, ok = retryPolicies.policies.DeviceTypes[action.Device.Type.ID]
+	}
+	if !ok {
+		policy = retryPolicies.policies.Default
+	}
+
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = DefaultInitialBackoff
+	}
+	if len(policy.RetryableStatusCodes) == 0 {
+		policy.RetryableStatusCodes = DefaultRetryableStatusCodes
+	}
+
+	return policy
+}
+
+func (p RetryPolicy) retryable(statusCode int, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	for _, code := range p.RetryableStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p RetryPolicy) nextBackoff(backoff time.Duration) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	backoff = time.Duration(float64(backoff) * multiplier)
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+
+	return backoff
+}
+
+// sendWithRetry sends a state-changing command, retrying it according to its retry policy until it succeeds,
+// fails with a non-retryable response, its device becomes unreachable, or ctx is done.
+func sendWithRetry(ctx context.Context, action base.ActionStructure, request transport.Request) (transport.Response, error) {
+	policy := retryPolicyFor(action)
+	backoff := policy.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		resp, err := transport.Send(ctx, request)
+		if ctx.Err() != nil {
+			return resp, err
+		}
+
+		recordCommandResult(action.Device, resp.StatusCode, err)
+		observeCommand(action.Device.ID, action.Action, start, resp.StatusCode, err)
+
+		if attempt >= policy.MaxAttempts || !policy.retryable(resp.StatusCode, err) || deviceUnreachable(action.Device.ID) {
+			return resp, err
+		}
+
+		reason := fmt.Sprintf("response code %d", resp.StatusCode)
+		if err != nil {
+			reason = err.Error()
+		}
+
+		log.L.Warnf("%s", color.HiYellowString("[state] attempt %d/%d of %s on %s failed (%s); retrying in %s", attempt, policy.MaxAttempts, action.Action, action.Device.ID, reason, backoff))
+
+		select {
+		case <-ctx.Done():
+			return resp, err
+		case <-time.After(backoff):
+		}
+
+		backoff = policy.nextBackoff(backoff)
+	}
+}
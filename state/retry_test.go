@@ -0,0 +1,162 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/common/structs"
+)
+
+func TestExecuteCommandRetriesRetryableResponses(t *testing.T) {
+	t.Setenv("ROOM_SYSTEM", "true")
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(`{"power":"on"}`))
+	}))
+	defer server.Close()
+
+	SetRetryPolicies(RetryPolicies{
+		Default: RetryPolicy{MaxAttempts: 1},
+		Commands: map[string]RetryPolicy{
+			"PowerOn": {MaxAttempts: 3, InitialBackoff: time.Millisecond},
+		},
+	})
+	defer SetRetryPolicies(RetryPolicies{Default: RetryPolicy{MaxAttempts: 1}})
+
+	device := structs.Device{ID: "TEST-RETRY-D1", Name: "D1"}
+	defer recordDeviceSuccess(device)
+
+	response := ExecuteCommandWithContext(context.Background(), base.ActionStructure{Action: "PowerOn", Device: device}, server.URL+"/power/on", "test")
+	if response.ErrorMessage != nil {
+		t.Fatalf("expected the command to succeed after retrying, got %s", *response.ErrorMessage)
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestExecuteCommandDoesNotRetryOtherResponses(t *testing.T) {
+	t.Setenv("ROOM_SYSTEM", "true")
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	SetRetryPolicies(RetryPolicies{
+		DeviceTypes: map[string]RetryPolicy{
+			"TestDisplay": {MaxAttempts: 5, InitialBackoff: time.Millisecond},
+		},
+	})
+	defer SetRetryPolicies(RetryPolicies{Default: RetryPolicy{MaxAttempts: 1}})
+
+	device := structs.Device{ID: "TEST-RETRY-D2", Name: "D2", Type: structs.DeviceType{ID: "TestDisplay"}}
+	defer recordDeviceSuccess(device)
+
+	ExecuteCommandWithContext(context.Background(), base.ActionStructure{Action: "PowerOn", Device: device}, server.URL+"/power/on", "test")
+
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Fatalf("expected a single attempt, got %d", got)
+	}
+}
+
+func TestExecuteCommandStopsRetryingWhenCanceled(t *testing.T) {
+	t.Setenv("ROOM_SYSTEM", "true")
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	SetRetryPolicies(RetryPolicies{
+		Default: RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Hour},
+	})
+	defer SetRetryPolicies(RetryPolicies{Default: RetryPolicy{MaxAttempts: 1}})
+
+	device := structs.Device{ID: "TEST-RETRY-D3", Name: "D3"}
+	defer recordDeviceSuccess(device)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		ExecuteCommandWithContext(ctx, base.ActionStructure{Action: "PowerOn", Device: device}, server.URL+"/power/on", "test")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the retry backoff to end when the context was canceled")
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Fatalf("expected a single attempt before cancellation, got %d", got)
+	}
+}
+
+func TestRetryPolicyUnmarshalsDurations(t *testing.T) {
+	var policies RetryPolicies
+	err := json.Unmarshal([]byte(`{"default":{"maxAttempts":3,"initialBackoff":"250ms","maxBackoff":"2s","retryableStatusCodes":[503]}}`), &policies)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	policy := policies.Default
+	if policy.MaxAttempts != 3 || policy.InitialBackoff != 250*time.Millisecond || policy.MaxBackoff != 2*time.Second {
+		t.Fatalf("unexpected policy: %+v", policy)
+	}
+
+	if policy.nextBackoff(2*time.Second) != 2*time.Second {
+		t.Fatalf("expected backoff to be capped at %s", policy.MaxBackoff)
+	}
+
+	if !policy.retryable(http.StatusServiceUnavailable, nil) || policy.retryable(http.StatusBadGateway, nil) {
+		t.Fatalf("unexpected retryable status codes: %v", policy.RetryableStatusCodes)
+	}
+}
+
+func TestRetryPolicyDefaultsInitialBackoff(t *testing.T) {
+	var policies RetryPolicies
+	err := json.Unmarshal([]byte(`{"default":{"maxAttempts":3},"commands":{"PowerOn":{"maxAttempts":2,"initialBackoff":"0s"}}}`), &policies)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if policies.Default.InitialBackoff != DefaultInitialBackoff {
+		t.Fatalf("expected a missing initialBackoff to be %s, got %s", DefaultInitialBackoff, policies.Default.InitialBackoff)
+	}
+	if policies.Commands["PowerOn"].InitialBackoff != DefaultInitialBackoff {
+		t.Fatalf("expected a zero initialBackoff to be %s, got %s", DefaultInitialBackoff, policies.Commands["PowerOn"].InitialBackoff)
+	}
+
+	// policies set in code get the same default
+	SetRetryPolicies(RetryPolicies{Default: RetryPolicy{MaxAttempts: 3}})
+	defer SetRetryPolicies(RetryPolicies{Default: RetryPolicy{MaxAttempts: 1}})
+
+	policy := retryPolicyFor(base.ActionStructure{Action: "PowerOn"})
+	if policy.InitialBackoff != DefaultInitialBackoff {
+		t.Fatalf("expected the default initial backoff, got %s", policy.InitialBackoff)
+	}
+	if policy.nextBackoff(policy.InitialBackoff) <= policy.InitialBackoff {
+		t.Fatalf("expected the backoff to grow from %s", policy.InitialBackoff)
+	}
+}
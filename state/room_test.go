@@ -0,0 +1,176 @@
+package state
+
+import (
+	"context"
+	"testing"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/config"
+	"github.com/byuoitav/av-api/internal/testdevice"
+	"github.com/byuoitav/common/structs"
+)
+
+// useTestDevices serves rooms from an in-memory database whose devices are simulated by a testdevice.Server.
+func useTestDevices(t *testing.T, rooms func(s *testdevice.Server) []structs.Room) *testdevice.Server {
+	t.Helper()
+
+	// room systems don't need a bearer token to send commands
+	t.Setenv("ROOM_SYSTEM", "true")
+	t.Setenv("DATA_DIR", t.TempDir())
+
+	server := testdevice.NewServer()
+	t.Cleanup(server.Close)
+
+	config.UseDB(testdevice.NewDB(rooms(server)...))
+	return server
+}
+
+func TestSetAndGetDefaultRoom(t *testing.T) {
+	server := useTestDevices(t, func(s *testdevice.Server) []structs.Room {
+		return []structs.Room{s.DefaultRoom()}
+	})
+
+	volume := 30
+	muted := true
+	report, err := SetRoomStateWithContext(context.Background(), base.PublicRoom{
+		Building: "ITB",
+		Room:     "1101",
+		Power:    "on",
+		Displays: []base.Display{{Device: base.Device{Name: "D1", Input: "HDMI2"}}},
+		AudioDevices: []base.AudioDevice{
+			{Device: base.Device{Name: "D2"}, Volume: &volume, Muted: &muted},
+		},
+	}, "test")
+	if err != nil {
+		t.Fatalf("unable to set room state: %s", err)
+	}
+
+	if d1, ok := findDisplay(report, "D1"); !ok || d1.Power != "on" || d1.Input != "HDMI2" {
+		t.Fatalf("unexpected report for D1: %+v", report.Displays)
+	}
+
+	if state := server.State("itb-1101-d1.test"); state.Power != "on" || state.Input != "hdmi2" {
+		t.Fatalf("unexpected state for D1: %+v", state)
+	}
+	if state := server.State("itb-1101-d2.test"); state.Power != "on" || state.Volume != 30 || !state.Muted {
+		t.Fatalf("unexpected state for D2: %+v", state)
+	}
+
+	status, err := GetRoomStateWithContext(context.Background(), "ITB", "1101")
+	if err != nil {
+		t.Fatalf("unable to get room state: %s", err)
+	}
+
+	if d1, ok := findDisplay(status, "D1"); !ok || d1.Power != "on" || d1.Input != "HDMI2" || d1.Blanked == nil || *d1.Blanked {
+		t.Fatalf("unexpected status for D1: %+v", status.Displays)
+	}
+	if d2, ok := findAudioDevice(status, "D2"); !ok || d2.Volume == nil || *d2.Volume != 30 || d2.Muted == nil || !*d2.Muted {
+		t.Fatalf("unexpected status for D2: %+v", status.AudioDevices)
+	}
+}
+
+func TestSetAndGetDSPRoom(t *testing.T) {
+	server := useTestDevices(t, func(s *testdevice.Server) []structs.Room {
+		return []structs.Room{s.DSPRoom()}
+	})
+
+	volume := 40
+	muted := true
+	_, err := SetRoomStateWithContext(context.Background(), base.PublicRoom{
+		Building: "ITB",
+		Room:     "1102",
+		AudioDevices: []base.AudioDevice{
+			{Device: base.Device{Name: "MIC1"}, Volume: &volume},
+			{Device: base.Device{Name: "DSP1"}, Muted: &muted},
+		},
+	}, "test")
+	if err != nil {
+		t.Fatalf("unable to set room state: %s", err)
+	}
+
+	state := server.State("itb-1102-dsp1.test")
+	if state.Channels["1"].Volume != 40 {
+		t.Fatalf("expected the mic's channel to be at 40, got %+v", state.Channels)
+	}
+	if !state.Channels["2"].Muted {
+		t.Fatalf("expected the media channel to be muted, got %+v", state.Channels)
+	}
+
+	status, err := GetRoomStateWithContext(context.Background(), "ITB", "1102")
+	if err != nil {
+		t.Fatalf("unable to get room state: %s", err)
+	}
+
+	if mic, ok := findAudioDevice(status, "MIC1"); !ok || mic.Volume == nil || *mic.Volume != 40 {
+		t.Fatalf("unexpected status for MIC1: %+v", status.AudioDevices)
+	}
+}
+
+func TestSetVideoSwitcherRoom(t *testing.T) {
+	server := useTestDevices(t, func(s *testdevice.Server) []structs.Room {
+		return []structs.Room{s.VideoSwitcherRoom()}
+	})
+
+	report, err := SetRoomStateWithContext(context.Background(), base.PublicRoom{
+		Building: "ITB",
+		Room:     "1103",
+		Power:    "on",
+		Displays: []base.Display{{Device: base.Device{Name: "D1", Input: "ITB-1103-HDMI2"}}},
+	}, "test")
+	if err != nil {
+		t.Fatalf("unable to set room state: %s", err)
+	}
+
+	if d1, ok := findDisplay(report, "D1"); !ok || d1.Power != "on" {
+		t.Fatalf("unexpected report for D1: %+v", report.Displays)
+	}
+	if routes := server.State("itb-1103-sw1.test").Routes; routes["1"] != "2" {
+		t.Fatalf("expected HDMI2 to be routed to D1, got %+v", routes)
+	}
+
+	status, err := GetRoomStateWithContext(context.Background(), "ITB", "1103")
+	if err != nil {
+		t.Fatalf("unable to get room state: %s", err)
+	}
+	if d1, ok := findDisplay(status, "D1"); !ok || d1.Power != "on" {
+		t.Fatalf("unexpected status for D1: %+v", status.Displays)
+	}
+}
+
+func TestSetAndGetTieredRoom(t *testing.T) {
+	server := useTestDevices(t, func(s *testdevice.Server) []structs.Room {
+		return []structs.Room{s.TieredRoom()}
+	})
+
+	_, err := SetRoomStateWithContext(context.Background(), base.PublicRoom{
+		Building: "ITB",
+		Room:     "1104",
+		Power:    "on",
+		Displays: []base.Display{
+			{Device: base.Device{Name: "D1", Input: "HDMI2"}},
+			{Device: base.Device{Name: "D2", Input: "HDMI3"}},
+		},
+	}, "test")
+	if err != nil {
+		t.Fatalf("unable to set room state: %s", err)
+	}
+
+	if routes := server.State("itb-1104-sw1.test").Routes; routes["1"] != "2" {
+		t.Fatalf("expected HDMI2 to be routed out of SW1, got %+v", routes)
+	}
+	if routes := server.State("itb-1104-sw2.test").Routes; routes["1"] != "1" || routes["2"] != "2" {
+		t.Fatalf("expected SW1 to be routed to D1 and HDMI3 to D2, got %+v", routes)
+	}
+
+	status, err := GetRoomStateWithContext(context.Background(), "ITB", "1104")
+	if err != nil {
+		t.Fatalf("unable to get room state: %s", err)
+	}
+
+	if d1, ok := findDisplay(status, "D1"); !ok || d1.Power != "on" || d1.Input != "HDMI2" {
+		t.Fatalf("unexpected status for D1: %+v", status.Displays)
+	}
+	if d2, ok := findDisplay(status, "D2"); !ok || d2.Input != "HDMI3" {
+		t.Fatalf("unexpected status for D2: %+v", status.Displays)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/byuoitav/av-api/actionreconcilers"
 	"github.com/byuoitav/av-api/base"
@@ -18,9 +19,16 @@ import (
 
 // GenerateActions evaluates and validates each command in the configuration.
 func GenerateActions(dbRoom structs.Room, bodyRoom base.PublicRoom, requestor string) ([]base.ActionStructure, int, error) {
+	return GenerateActionsWithContext(context.Background(), dbRoom, bodyRoom, requestor)
+}
+
+// GenerateActionsWithContext evaluates and validates each command in the configuration, recording each evaluator in the request's trace.
+func GenerateActionsWithContext(ctx context.Context, dbRoom structs.Room, bodyRoom base.PublicRoom, requestor string) ([]base.ActionStructure, int, error) {
 
 	log.L.Infof("%s", color.HiBlueString("[state] generating actions..."))
 
+	trace := tracerFromContext(ctx)
+
 	var count int
 
 	var output []base.ActionStructure
@@ -37,7 +45,9 @@ func GenerateActions(dbRoom structs.Room, bodyRoom base.PublicRoom, requestor st
 			return []base.ActionStructure{}, 0, errors.New(msg)
 		}
 
+		evaluateStart := time.Now()
 		actions, c, err := curEvaluator.Evaluate(dbRoom, bodyRoom, requestor)
+		trace.evaluator(evaluator.CodeKey, evaluateStart, len(actions), err)
 		if err != nil {
 			return []base.ActionStructure{}, 0, err
 		}
@@ -61,7 +71,9 @@ func GenerateActions(dbRoom structs.Room, bodyRoom base.PublicRoom, requestor st
 
 	log.L.Infof("%s", color.HiBlueString("[state] generated %v total actions.", len(output)))
 
+	reconcileStart := time.Now()
 	batches, count, err := ReconcileActions(dbRoom, output, count)
+	trace.reconciler(dbRoom.Configuration.Description, reconcileStart, batches, count, err)
 
 	return batches, count, err
 }
@@ -115,7 +127,8 @@ func ExecuteActionsWithContext(ctx context.Context, DAG []base.ActionStructure,
 
 	var schedule func(base.ActionStructure)
 	schedule = func(action base.ActionStructure) {
-		if ctx.Err() != nil {
+		if err := ctx.Err(); err != nil {
+			tracerFromContext(ctx).skipped(action, err.Error())
 			return
 		}
 
@@ -166,14 +179,18 @@ func ExecuteAction(action base.ActionStructure, responses chan<- se.StatusRespon
 func executeAction(ctx context.Context, action base.ActionStructure, responses chan<- se.StatusResponse, requestor string, schedule func(base.ActionStructure)) {
 	log.L.Infof("[state] Executing action %s against device %s...", action.Action, action.Device.Name)
 
+	trace := tracerFromContext(ctx)
+
 	if err := ctx.Err(); err != nil {
 		log.L.Warnf("[state] Skipping action %s on device %s: %s", action.Action, action.Device.Name, err)
+		trace.skipped(action, err.Error())
 		return
 	}
 
 	if action.Overridden {
 		log.L.Infof("[state] Action %s on device %s have been overridden. Continuing.",
 			action.Action, action.Device.Name)
+		trace.skipped(action, "overridden")
 		return
 	}
 
@@ -198,22 +215,12 @@ func executeAction(ctx context.Context, action base.ActionStructure, responses c
 		}
 	*/
 
-	url, err := action.Device.BuildCommandURL(action.Action)
+	url, err := buildActionURL(action)
 	if err != nil {
 		msg := fmt.Sprintf("unable to execute action '%s' on %s: %s", action.Action, action.Device.ID, err.Error())
 		log.L.Errorf("%s", color.HiRedString("[state] %s", msg))
 		PublishError(msg, action, requestor)
-		return
-	}
-
-	url = strings.Replace(url, ":address", action.Device.Address, -1)
-
-	var gerr error
-	url, gerr = ReplaceParameters(url, action.Parameters)
-	if gerr != nil {
-		msg := fmt.Sprintf("unable to execute action '%s' on %s: %s", action.Action, action.Device.ID, gerr.Error())
-		log.L.Errorf("%s", color.HiRedString("[state] %s", msg))
-		PublishError(msg, action, requestor)
+		trace.skipped(action, msg)
 		return
 	}
 
@@ -222,6 +229,9 @@ func executeAction(ctx context.Context, action base.ActionStructure, responses c
 
 	if err := ctx.Err(); err != nil {
 		log.L.Warnf("[state] Command %s on device %s canceled: %s", action.Action, action.Device.Name, err)
+		for _, child := range action.Children {
+			trace.skipped(*child, err.Error())
+		}
 		return
 	}
 
@@ -239,6 +249,18 @@ func executeAction(ctx context.Context, action base.ActionStructure, responses c
 	}
 }
 
+// buildActionURL resolves the microservice URL for an action, filling in the device address and parameters.
+func buildActionURL(action base.ActionStructure) (string, error) {
+	url, nerr := action.Device.BuildCommandURL(action.Action)
+	if nerr != nil {
+		return "", nerr
+	}
+
+	url = strings.Replace(url, ":address", action.Device.Address, -1)
+
+	return ReplaceParameters(url, action.Parameters)
+}
+
 // SET_STATE_STATUS_EVALUATORS is the map containing the definitions of our evaluator strings.
 // this is where we decide which status evaluator is used to evalutate the resultant status of a command that sets state
 var SET_STATE_STATUS_EVALUATORS = map[string]string{
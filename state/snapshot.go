@@ -0,0 +1,260 @@
+package state
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/common/log"
+)
+
+const (
+	// SnapshotInterval is how often a room system refreshes its room's state snapshot.
+	SnapshotInterval = 15 * time.Second
+
+	roomSnapshotTimeout = 30 * time.Second
+)
+
+var sendEvent = base.SendEvent
+
+type roomSnapshot struct {
+	room         base.PublicRoom
+	lastUpdated  time.Time
+	displays     map[string]time.Time
+	audioDevices map[string]time.Time
+
+	// started is when the read the snapshot was last built from began, and lastSet is the last time a change
+	// through the API was running. Changes found by a read that may have overlapped a change aren't published,
+	// since the change published its own events.
+	started time.Time
+	lastSet time.Time
+
+	// set is the report of the last successful change, applied to the snapshot when it finished. It's applied again
+	// to a read that started before then, so the snapshot never goes back to the state before the change.
+	set     *base.PublicRoom
+	setDone time.Time
+
+	refresh chan struct{}
+}
+
+var roomSnapshots = struct {
+	sync.Mutex
+	rooms map[string]*roomSnapshot
+}{
+	rooms: make(map[string]*roomSnapshot),
+}
+
+// StartSnapshotPoller keeps a snapshot of a room's state until ctx is done, refreshing it every interval and after
+// every successful change made through the API. Changes found between refreshes are published as detection events, and
+// each refresh is sent to the room's subscribers in place of their own polling.
+func StartSnapshotPoller(ctx context.Context, building string, roomName string, interval time.Duration) {
+	key := roomKey(building, roomName)
+	snapshot := &roomSnapshot{
+		displays:     make(map[string]time.Time),
+		audioDevices: make(map[string]time.Time),
+		refresh:      make(chan struct{}, 1),
+	}
+
+	roomSnapshots.Lock()
+	roomSnapshots.rooms[key] = snapshot
+	roomSnapshots.Unlock()
+
+	defer func() {
+		roomSnapshots.Lock()
+		if roomSnapshots.rooms[key] == snapshot {
+			delete(roomSnapshots.rooms, key)
+		}
+		roomSnapshots.Unlock()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		refreshRoomSnapshot(ctx, building, roomName, snapshot)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-snapshot.refresh:
+		}
+	}
+}
+
+func refreshRoomSnapshot(ctx context.Context, building string, roomName string, snapshot *roomSnapshot) {
+	key := roomKey(building, roomName)
+	started := time.Now()
+
+	readCtx, cancel := context.WithTimeout(ctx, roomSnapshotTimeout)
+	status, err := GetRoomStateWithContext(readCtx, building, roomName)
+	cancel()
+	if err != nil {
+		log.L.Warnf("[state] unable to refresh room state snapshot for %s: %s", key, err)
+		return
+	}
+
+	now := time.Now()
+
+	roomSnapshots.Lock()
+	previous, hadPrevious := snapshot.room, !snapshot.lastUpdated.IsZero()
+	quiet := snapshot.lastSet.After(snapshot.started)
+
+	if snapshot.set != nil && snapshot.setDone.After(started) {
+		status = overlayPublicRoom(status, *snapshot.set)
+	}
+
+	for i, display := range status.Displays {
+		if !display.Unreachable {
+			snapshot.displays[display.Name] = now
+			continue
+		}
+
+		// keep the last state read from a device that can't be reached
+		if old, ok := findDisplay(previous, display.Name); ok {
+			old.Unreachable = true
+			status.Displays[i] = old
+		}
+	}
+
+	for i, audioDevice := range status.AudioDevices {
+		if !audioDevice.Unreachable {
+			snapshot.audioDevices[audioDevice.Name] = now
+			continue
+		}
+
+		if old, ok := findAudioDevice(previous, audioDevice.Name); ok {
+			old.Unreachable = true
+			status.AudioDevices[i] = old
+		}
+	}
+
+	snapshot.room = status
+	snapshot.lastUpdated = now
+	snapshot.started = started
+	roomSnapshots.Unlock()
+
+	publishRoomStateSnapshot(key, status)
+
+	if !hadPrevious || quiet {
+		return
+	}
+
+	detected := DetectChanges(key, previous, status)
+	if len(detected) == 0 {
+		return
+	}
+
+	log.L.Infof("[state] detected %d changes to the state of %s made outside the API", len(detected), key)
+	for _, e := range detected {
+		sendEvent(e)
+	}
+}
+
+// snapshotPollerRunning reports whether a room has a snapshot poller, even if it hasn't finished its first refresh.
+func snapshotPollerRunning(key string) bool {
+	roomSnapshots.Lock()
+	defer roomSnapshots.Unlock()
+
+	_, ok := roomSnapshots.rooms[key]
+	return ok
+}
+
+// noteRoomStateSet records that a change to a room's state is running or just finished. Once a change succeeds, the
+// room's snapshot is refreshed.
+func noteRoomStateSet(key string, succeeded bool) {
+	roomSnapshots.Lock()
+	defer roomSnapshots.Unlock()
+
+	snapshot, ok := roomSnapshots.rooms[key]
+	if !ok {
+		return
+	}
+
+	snapshot.lastSet = time.Now()
+	if !succeeded {
+		return
+	}
+
+	select {
+	case snapshot.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// applyRoomStateSet applies the report of a successful change to a room's snapshot, so a change made right after it,
+// like another volumeDelta, is resolved against the state the change left the room in rather than the one before it.
+func applyRoomStateSet(key string, report base.PublicRoom) {
+	roomSnapshots.Lock()
+	defer roomSnapshots.Unlock()
+
+	snapshot, ok := roomSnapshots.rooms[key]
+	if !ok || snapshot.lastUpdated.IsZero() {
+		return
+	}
+
+	report.Trace = nil
+	snapshot.room = overlayPublicRoom(snapshot.room, report)
+	snapshot.set = &report
+	snapshot.setDone = time.Now()
+}
+
+// GetRoomSnapshot returns the last state read by a room's snapshot poller, and whether there is one. Each device's
+// LastUpdated is when it was last read, and devices that couldn't be read by the latest refresh are marked stale.
+func GetRoomSnapshot(building string, roomName string) (base.PublicRoom, bool) {
+	roomSnapshots.Lock()
+	defer roomSnapshots.Unlock()
+
+	snapshot, ok := roomSnapshots.rooms[roomKey(building, roomName)]
+	if !ok || snapshot.lastUpdated.IsZero() {
+		return base.PublicRoom{}, false
+	}
+
+	room := snapshot.room
+	lastUpdated := snapshot.lastUpdated
+	room.LastUpdated = &lastUpdated
+
+	room.Displays = append([]base.Display(nil), snapshot.room.Displays...)
+	for i := range room.Displays {
+		stampDevice(&room.Displays[i].Device, snapshot.displays, lastUpdated)
+	}
+
+	room.AudioDevices = append([]base.AudioDevice(nil), snapshot.room.AudioDevices...)
+	for i := range room.AudioDevices {
+		stampDevice(&room.AudioDevices[i].Device, snapshot.audioDevices, lastUpdated)
+	}
+
+	return room, true
+}
+
+func findDisplay(room base.PublicRoom, name string) (base.Display, bool) {
+	for _, display := range room.Displays {
+		if display.Name == name {
+			return display, true
+		}
+	}
+
+	return base.Display{}, false
+}
+
+func findAudioDevice(room base.PublicRoom, name string) (base.AudioDevice, bool) {
+	for _, audioDevice := range room.AudioDevices {
+		if audioDevice.Name == name {
+			return audioDevice, true
+		}
+	}
+
+	return base.AudioDevice{}, false
+}
+
+func stampDevice(device *base.Device, updated map[string]time.Time, lastUpdated time.Time) {
+	t, ok := updated[device.Name]
+	if !ok {
+		device.Stale = true
+		return
+	}
+
+	device.LastUpdated = &t
+	device.Stale = t.Before(lastUpdated)
+}
@@ -0,0 +1,282 @@
+package state
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/internal/testdevice"
+	"github.com/byuoitav/common/structs"
+	"github.com/byuoitav/common/v2/events"
+)
+
+// startTestSnapshotPoller runs a snapshot poller for ITB-1101 that only refreshes when told to, and collects the
+// events it sends.
+func startTestSnapshotPoller(t *testing.T) (*testdevice.Server, func() []events.Event) {
+	t.Helper()
+
+	server := useTestDevices(t, func(s *testdevice.Server) []structs.Room {
+		return []structs.Room{s.DefaultRoom()}
+	})
+
+	var mu sync.Mutex
+	var sent []events.Event
+
+	originalSendEvent := sendEvent
+	sendEvent = func(e events.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		sent = append(sent, e)
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		StartSnapshotPoller(ctx, "ITB", "1101", time.Hour)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		sendEvent = originalSendEvent
+	})
+
+	waitForSnapshot(t, func(room base.PublicRoom) bool { return true })
+
+	return server, func() []events.Event {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]events.Event(nil), sent...)
+	}
+}
+
+func waitForSnapshot(t *testing.T, ok func(room base.PublicRoom) bool) base.PublicRoom {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if room, found := GetRoomSnapshot("ITB", "1101"); found && ok(room) {
+			return room
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("timed out waiting for the room state snapshot")
+	return base.PublicRoom{}
+}
+
+// waitForSnapshotRefreshAfterSet waits for the refresh that follows the last change made through the API to finish.
+func waitForSnapshotRefreshAfterSet(t *testing.T) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		roomSnapshots.Lock()
+		snapshot := roomSnapshots.rooms[roomKey("ITB", "1101")]
+		refreshed := snapshot.started.After(snapshot.setDone)
+		roomSnapshots.Unlock()
+
+		if refreshed {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("timed out waiting for the room state snapshot to be refreshed after the change")
+}
+
+func triggerSnapshotRefresh(t *testing.T) {
+	t.Helper()
+
+	roomSnapshots.Lock()
+	snapshot := roomSnapshots.rooms[roomKey("ITB", "1101")]
+	roomSnapshots.Unlock()
+
+	snapshot.refresh <- struct{}{}
+}
+
+func TestRoomSnapshotPublishesChangesMadeOutsideTheAPI(t *testing.T) {
+	server, sent := startTestSnapshotPoller(t)
+
+	first := waitForSnapshot(t, func(room base.PublicRoom) bool { return true })
+	if first.LastUpdated == nil {
+		t.Fatal("expected the snapshot to have a lastUpdated time")
+	}
+
+	// someone turns D1 on with its remote
+	d1 := server.State("itb-1101-d1.test")
+	d1.Power = "on"
+	server.SetState("itb-1101-d1.test", d1)
+
+	triggerSnapshotRefresh(t)
+	room := waitForSnapshot(t, func(room base.PublicRoom) bool {
+		display, ok := findDisplay(room, "D1")
+		return ok && display.Power == "on"
+	})
+
+	if !room.LastUpdated.After(*first.LastUpdated) {
+		t.Fatalf("expected lastUpdated to move forward from %s, got %s", first.LastUpdated, room.LastUpdated)
+	}
+
+	got := sent()
+	if len(got) != 1 {
+		t.Fatalf("expected one event, got %+v", got)
+	}
+	if e := got[0]; e.Key != "power" || e.Value != "on" || e.TargetDevice.DeviceID != "ITB-1101-D1" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	if change, ok := got[0].Data.(DetectedChange); !ok || change.Previous != "standby" || change.Current != "on" {
+		t.Fatalf("expected the event to record the change from standby to on, got %+v", got[0].Data)
+	}
+}
+
+func TestRoomSnapshotRefreshesQuietlyAfterSet(t *testing.T) {
+	server, sent := startTestSnapshotPoller(t)
+
+	_, err := SetRoomStateLatest(context.Background(), base.PublicRoom{
+		Building: "ITB",
+		Room:     "1101",
+		Displays: []base.Display{{Device: base.Device{Name: "D2", Power: "on"}}},
+	}, "test")
+	if err != nil {
+		t.Fatalf("unable to set room state: %s", err)
+	}
+
+	waitForSnapshot(t, func(room base.PublicRoom) bool {
+		display, ok := findDisplay(room, "D2")
+		return ok && display.Power == "on"
+	})
+	waitForSnapshotRefreshAfterSet(t)
+
+	if got := sent(); len(got) != 0 {
+		t.Fatalf("expected changes made through the API not to be published again, got %+v", got)
+	}
+
+	// the next refresh is no longer quiet
+	d2 := server.State("itb-1101-d2.test")
+	d2.Power = "standby"
+	server.SetState("itb-1101-d2.test", d2)
+
+	triggerSnapshotRefresh(t)
+	waitForSnapshot(t, func(room base.PublicRoom) bool {
+		display, ok := findDisplay(room, "D2")
+		return ok && display.Power == "standby"
+	})
+
+	if got := sent(); len(got) != 1 || got[0].Value != "standby" {
+		t.Fatalf("expected one standby event, got %+v", got)
+	}
+}
+
+func TestRoomSnapshotMarksDevicesNotReadByTheLastRefreshStale(t *testing.T) {
+	room := base.PublicRoom{
+		Displays: []base.Display{{Device: base.Device{Name: "D1", Power: "on"}}},
+	}
+
+	roomSnapshots.Lock()
+	snapshot := &roomSnapshot{
+		room:         room,
+		lastUpdated:  time.Now(),
+		displays:     map[string]time.Time{"D1": time.Now().Add(-time.Minute)},
+		audioDevices: map[string]time.Time{},
+	}
+	roomSnapshots.rooms[roomKey("ITB", "1199")] = snapshot
+	roomSnapshots.Unlock()
+
+	defer func() {
+		roomSnapshots.Lock()
+		delete(roomSnapshots.rooms, roomKey("ITB", "1199"))
+		roomSnapshots.Unlock()
+	}()
+
+	served, ok := GetRoomSnapshot("ITB", "1199")
+	if !ok {
+		t.Fatal("expected a snapshot")
+	}
+
+	display, _ := findDisplay(served, "D1")
+	if !display.Stale || display.LastUpdated == nil || display.Power != "on" {
+		t.Fatalf("expected D1 to be stale, got %+v", display)
+	}
+}
+
+func TestRoomSnapshotFeedsSubscribersWithoutPollingAgain(t *testing.T) {
+	server, _ := startTestSnapshotPoller(t)
+	server.Reset()
+
+	updates, unsubscribe := SubscribeRoomState("ITB", "1101")
+	defer unsubscribe()
+
+	select {
+	case update := <-updates:
+		if update.Source != UpdateSourcePoll || len(update.Room.Displays) == 0 {
+			t.Fatalf("expected the first update to be the whole snapshot, got %+v", update)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the snapshot")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if requests := server.Requests(); len(requests) != 0 {
+		t.Fatalf("expected subscribing not to read the devices, got %v", requests)
+	}
+
+	d1 := server.State("itb-1101-d1.test")
+	d1.Power = "on"
+	server.SetState("itb-1101-d1.test", d1)
+	triggerSnapshotRefresh(t)
+
+	select {
+	case update := <-updates:
+		display, ok := findDisplay(update.Room, "D1")
+		if update.Source != UpdateSourcePoll || len(update.Room.Displays) != 1 || !ok || display.Power != "on" {
+			t.Fatalf("expected only D1's power in the update, got %+v", update)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the refresh to be published")
+	}
+}
+
+func TestRoomSnapshotResolvesSequentialVolumeDeltas(t *testing.T) {
+	server, _ := startTestSnapshotPoller(t)
+
+	d1 := server.State("itb-1101-d1.test")
+	d1.Volume = 30
+	server.SetState("itb-1101-d1.test", d1)
+
+	triggerSnapshotRefresh(t)
+	waitForSnapshot(t, func(room base.PublicRoom) bool {
+		audioDevice, ok := findAudioDevice(room, "D1")
+		return ok && audioDevice.Volume != nil && *audioDevice.Volume == 30
+	})
+
+	// the second change is resolved before the snapshot is refreshed after the first
+	delta := 5
+	for i := 0; i < 2; i++ {
+		_, err := SetRoomStateLatest(context.Background(), base.PublicRoom{
+			Building:     "ITB",
+			Room:         "1101",
+			AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D1"}, VolumeDelta: &delta}},
+		}, "test")
+		if err != nil {
+			t.Fatalf("unable to change the volume: %s", err)
+		}
+	}
+
+	if volume := server.State("itb-1101-d1.test").Volume; volume != 40 {
+		t.Fatalf("expected both volumeDeltas to be applied, got a volume of %d", volume)
+	}
+
+	waitForSnapshot(t, func(room base.PublicRoom) bool {
+		audioDevice, ok := findAudioDevice(room, "D1")
+		return ok && audioDevice.Volume != nil && *audioDevice.Volume == 40
+	})
+}
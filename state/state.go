@@ -6,8 +6,8 @@ import (
 	"time"
 
 	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/av-api/statusevaluators"
-	"github.com/byuoitav/common/db"
 	"github.com/byuoitav/common/log"
 	"github.com/fatih/color"
 )
@@ -19,6 +19,11 @@ func GetRoomState(building string, roomName string) (base.PublicRoom, error) {
 
 // GetRoomStateWithContext assesses the state of the room and returns a PublicRoom object.
 func GetRoomStateWithContext(ctx context.Context, building string, roomName string) (base.PublicRoom, error) {
+	return GetRoomStateWithFilter(ctx, building, roomName, StatusFilter{})
+}
+
+// GetRoomStateWithFilter assesses the state of the part of the room that filter selects and returns a PublicRoom object.
+func GetRoomStateWithFilter(ctx context.Context, building string, roomName string, filter StatusFilter) (base.PublicRoom, error) {
 
 	start := time.Now()
 	color.Set(color.FgHiCyan, color.Bold)
@@ -27,23 +32,31 @@ func GetRoomStateWithContext(ctx context.Context, building string, roomName stri
 
 	roomID := fmt.Sprintf("%v-%v", building, roomName)
 	roomStart := time.Now()
-	room, err := db.GetDB().GetRoom(roomID)
+	room, err := config.GetRoom(roomID)
 	log.L.Infof("[state] GetRoom for %s took %s", roomID, time.Since(roomStart))
+	roomStatePhaseDuration.Since(roomStart, "GetRoom")
 	if err != nil {
 		return base.PublicRoom{}, err
 	}
 
 	//we get the number of actions generated
 	generateStart := time.Now()
-	commands, count, err := GenerateStatusCommands(room, statusevaluators.StatusEvaluatorMap)
+	commands, count, err := GenerateStatusCommandsWithFilter(room, statusevaluators.StatusEvaluatorMap, filter)
 	log.L.Infof("[state] GenerateStatusCommands for %s took %s and produced %d commands", roomID, time.Since(generateStart), len(commands))
+	roomStatePhaseDuration.Since(generateStart, "GenerateStatusCommands")
 	if err != nil {
 		return base.PublicRoom{}, err
 	}
 
+	// nothing in the room reports what the filter asked for
+	if len(commands) == 0 && len(filter.String()) > 0 {
+		return base.PublicRoom{Building: building, Room: roomName}, nil
+	}
+
 	runStart := time.Now()
 	responses, err := RunStatusCommandsWithContext(ctx, commands)
 	log.L.Infof("[state] RunStatusCommands for %s took %s and produced %d responses", roomID, time.Since(runStart), len(responses))
+	roomStatePhaseDuration.Since(runStart, "RunStatusCommands")
 	if err != nil {
 		return base.PublicRoom{}, err
 	}
@@ -51,10 +64,12 @@ func GetRoomStateWithContext(ctx context.Context, building string, roomName stri
 	evaluateStart := time.Now()
 	roomStatus, err := EvaluateResponsesWithContext(ctx, room, responses, count)
 	log.L.Infof("[state] EvaluateResponses for %s took %s", roomID, time.Since(evaluateStart))
+	roomStatePhaseDuration.Since(evaluateStart, "EvaluateResponses")
 	if err != nil {
 		return base.PublicRoom{}, err
 	}
 
+	markUnreachableDevices(room, &roomStatus)
 	roomStatus.Building = building
 	roomStatus.Room = roomName
 
@@ -70,40 +85,78 @@ func SetRoomState(target base.PublicRoom, requestor string) (base.PublicRoom, er
 	return SetRoomStateWithContext(context.Background(), target, requestor)
 }
 
-// SetRoomStateWithContext changes the state of the room and returns a PublicRoom object.
-func SetRoomStateWithContext(ctx context.Context, target base.PublicRoom, requestor string) (base.PublicRoom, error) {
+// SetRoomStateWithContext changes the state of the room and returns a PublicRoom object. Each change, whether it
+// succeeds or fails, is recorded in the room's audit log.
+func SetRoomStateWithContext(ctx context.Context, target base.PublicRoom, requestor string) (report base.PublicRoom, err error) {
 
 	log.L.Infof("%s", color.HiBlueString("[state] setting room state..."))
 
+	start := time.Now()
+	record := AuditRecord{
+		Time:      start,
+		Building:  target.Building,
+		Room:      target.Room,
+		Requestor: requestor,
+		Request:   target,
+	}
+
+	// actions are always traced, so the audit log has the outcome of each one
+	ctx = withTracer(ctx)
+	defer func() {
+		auditRoomState(record, start, tracerFromContext(ctx).finish(), report, err)
+	}()
+
 	if err := ctx.Err(); err != nil {
 		return base.PublicRoom{}, err
 	}
 
 	roomID := fmt.Sprintf("%v-%v", target.Building, target.Room)
-	room, err := db.GetDB().GetRoom(roomID)
+	room, err := config.GetRoom(roomID)
+	if err != nil {
+		return base.PublicRoom{}, err
+	}
+
+	target, err = resolveRelativeStateWithContext(ctx, target)
 	if err != nil {
 		return base.PublicRoom{}, err
 	}
 
 	//so here we need to know how many things we're actually expecting.
-	actions, count, err := GenerateActions(room, target, requestor)
+	options := setRoomStateOptionsFromContext(ctx)
+
+	actions, count, err := GenerateActionsWithContext(ctx, room, target, requestor)
 	if err != nil {
 		return base.PublicRoom{}, err
 	}
 
+	record.Actions = BuildRoomStatePlan(actions, count).Actions
+
 	responses, err := ExecuteActionsWithContext(ctx, actions, requestor)
 	if err != nil {
 		return base.PublicRoom{}, err
 	}
 
 	//here's where we then pass that information through so that we can make a decent decision.
-	report, err := EvaluateResponsesWithContext(ctx, room, responses, count)
+	report, err = EvaluateResponsesWithContext(ctx, room, responses, count)
 	if err != nil {
 		return base.PublicRoom{}, err
 	}
 
+	if options.Verify {
+		mismatches, err := VerifyRoomState(ctx, room, target, actions)
+		if err != nil {
+			log.L.Warnf("%s", color.HiYellowString("[state] unable to verify room state of %s: %s", roomID, err))
+		}
+
+		report.Mismatches = mismatches
+	}
+
+	markUnreachableDevices(room, &report)
 	report.Building = target.Building
 	report.Room = target.Room
+	if options.Trace {
+		report.Trace = tracerFromContext(ctx).finish()
+	}
 
 	color.Set(color.FgHiGreen, color.Bold)
 	log.L.Info("[state] successfully set room state")
@@ -0,0 +1,494 @@
+package state
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/common/log"
+	"github.com/byuoitav/common/v2/events"
+)
+
+const (
+	roomStateSubscriptionPollInterval = 5 * time.Second
+	roomStateSubscriptionCacheTTL     = 750 * time.Millisecond
+	roomStateSubscriptionTimeout      = 30 * time.Second
+	roomStateSubscriptionBuffer       = 16
+)
+
+// Sources of a RoomStateUpdate.
+const (
+	UpdateSourceSet   = "set"
+	UpdateSourceEvent = "event"
+	UpdateSourcePoll  = "poll"
+)
+
+// RoomStateUpdate is a change in a room's state pushed to subscribers. Room only contains the fields that changed, and
+// Removed the names of devices the room no longer reports.
+type RoomStateUpdate struct {
+	Source    string          `json:"source"`
+	Timestamp time.Time       `json:"timestamp"`
+	Room      base.PublicRoom `json:"room"`
+	Removed   []string        `json:"removed,omitempty"`
+}
+
+type roomStateFeed struct {
+	subscribers map[chan RoomStateUpdate]struct{}
+	last        *base.PublicRoom
+	cancel      context.CancelFunc
+}
+
+var roomStateFeeds = struct {
+	sync.Mutex
+	rooms map[string]*roomStateFeed
+}{
+	rooms: make(map[string]*roomStateFeed),
+}
+
+// SubscribeRoomState returns a channel of updates to a room's state and a function to end the subscription.
+// While a room has subscribers, its state is polled in the background so changes made outside the API are picked up.
+// A room with a snapshot poller isn't polled again; each refresh of its snapshot is published instead.
+func SubscribeRoomState(building string, roomName string) (<-chan RoomStateUpdate, func()) {
+	key := roomKey(building, roomName)
+	updates := make(chan RoomStateUpdate, roomStateSubscriptionBuffer)
+
+	snapshotted := snapshotPollerRunning(key)
+	snapshot, hasSnapshot := GetRoomSnapshot(building, roomName)
+
+	roomStateFeeds.Lock()
+	feed, ok := roomStateFeeds.rooms[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		feed = &roomStateFeed{
+			subscribers: make(map[chan RoomStateUpdate]struct{}),
+			cancel:      cancel,
+		}
+		roomStateFeeds.rooms[key] = feed
+
+		switch {
+		case !snapshotted:
+			go pollRoomState(ctx, building, roomName, feed)
+		case hasSnapshot:
+			// the first subscriber gets the whole room, like the first poll would send it
+			snapshot.LastUpdated = nil
+			feed.last = &snapshot
+			updates <- RoomStateUpdate{Source: UpdateSourcePoll, Timestamp: time.Now(), Room: snapshot}
+		}
+	}
+	feed.subscribers[updates] = struct{}{}
+	roomStateFeeds.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			roomStateFeeds.Lock()
+			defer roomStateFeeds.Unlock()
+
+			delete(feed.subscribers, updates)
+			if len(feed.subscribers) == 0 {
+				feed.cancel()
+				if roomStateFeeds.rooms[key] == feed {
+					delete(roomStateFeeds.rooms, key)
+				}
+			}
+		})
+	}
+
+	return updates, unsubscribe
+}
+
+func pollRoomState(ctx context.Context, building string, roomName string, feed *roomStateFeed) {
+	key := roomKey(building, roomName)
+	ticker := time.NewTicker(roomStateSubscriptionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := GetRoomStateShared(ctx, building, roomName, roomStateSubscriptionCacheTTL, roomStateSubscriptionTimeout)
+		if err != nil {
+			log.L.Warnf("[state] unable to poll room state for %s subscribers: %s", key, err)
+		} else {
+			publishPolledRoomState(key, feed, status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishPolledRoomState sends subscribers the differences between a full read of a room's state and the last one,
+// including the devices it no longer has, and keeps it as the room's last known state.
+func publishPolledRoomState(key string, feed *roomStateFeed, status base.PublicRoom) {
+	roomStateFeeds.Lock()
+	delta, changed := status, true
+	var removed []string
+	if feed.last != nil {
+		delta, changed = DiffPublicRoom(*feed.last, status)
+		removed = RemovedDevices(*feed.last, status)
+	}
+	feed.last = &status
+	roomStateFeeds.Unlock()
+
+	if changed || len(removed) > 0 {
+		publishRoomStateUpdate(key, UpdateSourcePoll, delta, removed)
+	}
+}
+
+// publishRoomStateSnapshot sends the subscribers of a room with a snapshot poller what changed in a refresh.
+func publishRoomStateSnapshot(key string, status base.PublicRoom) {
+	roomStateFeeds.Lock()
+	feed, ok := roomStateFeeds.rooms[key]
+	roomStateFeeds.Unlock()
+
+	if ok {
+		publishPolledRoomState(key, feed, status)
+	}
+}
+
+// publishRoomStateUpdate sends an update to each subscriber of the room. Slow subscribers miss updates instead of blocking the sender.
+func publishRoomStateUpdate(key string, source string, room base.PublicRoom, removed []string) {
+	roomStateFeeds.Lock()
+	defer roomStateFeeds.Unlock()
+
+	feed, ok := roomStateFeeds.rooms[key]
+	if !ok {
+		return
+	}
+
+	update := RoomStateUpdate{
+		Source:    source,
+		Timestamp: time.Now(),
+		Room:      room,
+		Removed:   removed,
+	}
+
+	for subscriber := range feed.subscribers {
+		select {
+		case subscriber <- update:
+		default:
+			log.L.Warnf("[state] dropping room state update for a slow subscriber of %s", key)
+		}
+	}
+}
+
+// publishRoomStateChange sends subscribers the fields of a partial room state, like a set's report, that differ from the
+// room's last known state, and applies them to it, so the next poll doesn't send them again.
+func publishRoomStateChange(key string, source string, room base.PublicRoom) {
+	roomStateFeeds.Lock()
+	feed, ok := roomStateFeeds.rooms[key]
+	if !ok {
+		roomStateFeeds.Unlock()
+		return
+	}
+
+	delta, changed := room, true
+	if feed.last != nil {
+		updated := overlayPublicRoom(*feed.last, room)
+		delta, changed = DiffPublicRoom(*feed.last, updated)
+		feed.last = &updated
+	}
+	roomStateFeeds.Unlock()
+
+	if changed {
+		publishRoomStateUpdate(key, source, delta, nil)
+	}
+}
+
+// publishRoomStateEvent translates a published state event into an update for the affected room's subscribers.
+func publishRoomStateEvent(e events.Event, destination base.DestinationDevice) {
+	room, ok := publicRoomFromEvent(e, destination)
+	if !ok {
+		return
+	}
+
+	publishRoomStateChange(e.AffectedRoom.RoomID, UpdateSourceEvent, room)
+}
+
+func publicRoomFromEvent(e events.Event, destination base.DestinationDevice) (base.PublicRoom, bool) {
+	deviceID := e.TargetDevice.DeviceID
+	if len(deviceID) == 0 {
+		deviceID = destination.ID
+	}
+
+	name := deviceID[strings.LastIndex(deviceID, "-")+1:]
+	if len(name) == 0 {
+		return base.PublicRoom{}, false
+	}
+
+	var room base.PublicRoom
+	device := base.Device{Name: name}
+	display := base.Display{}
+	audioDevice := base.AudioDevice{}
+
+	switch e.Key {
+	case "power":
+		device.Power = e.Value
+	case "input":
+		device.Input = e.Value
+	case "blanked":
+		blanked, err := strconv.ParseBool(e.Value)
+		if err != nil || !destination.Display {
+			return base.PublicRoom{}, false
+		}
+		display.Blanked = &blanked
+	case "muted":
+		muted, err := strconv.ParseBool(e.Value)
+		if err != nil || !destination.AudioDevice {
+			return base.PublicRoom{}, false
+		}
+		audioDevice.Muted = &muted
+	case "volume":
+		volume, err := strconv.Atoi(e.Value)
+		if err != nil || !destination.AudioDevice {
+			return base.PublicRoom{}, false
+		}
+		audioDevice.Volume = &volume
+	default:
+		return base.PublicRoom{}, false
+	}
+
+	if destination.Display && e.Key != "muted" && e.Key != "volume" {
+		display.Device = device
+		room.Displays = []base.Display{display}
+	}
+
+	if destination.AudioDevice && e.Key != "blanked" {
+		audioDevice.Device = device
+		room.AudioDevices = []base.AudioDevice{audioDevice}
+	}
+
+	if len(room.Displays) == 0 && len(room.AudioDevices) == 0 {
+		return base.PublicRoom{}, false
+	}
+
+	return room, true
+}
+
+// DiffPublicRoom returns the fields of current that differ from previous, and whether anything changed. A device in
+// previous that isn't in current isn't part of the diff; see RemovedDevices.
+func DiffPublicRoom(previous base.PublicRoom, current base.PublicRoom) (base.PublicRoom, bool) {
+	var delta base.PublicRoom
+	changed := false
+
+	if previous.CurrentVideoInput != current.CurrentVideoInput {
+		delta.CurrentVideoInput = current.CurrentVideoInput
+		changed = true
+	}
+	if previous.CurrentAudioInput != current.CurrentAudioInput {
+		delta.CurrentAudioInput = current.CurrentAudioInput
+		changed = true
+	}
+	if previous.Power != current.Power {
+		delta.Power = current.Power
+		changed = true
+	}
+	if !boolPointersEqual(previous.Blanked, current.Blanked) {
+		delta.Blanked = current.Blanked
+		changed = true
+	}
+	if !boolPointersEqual(previous.Muted, current.Muted) {
+		delta.Muted = current.Muted
+		changed = true
+	}
+	if !intPointersEqual(previous.Volume, current.Volume) {
+		delta.Volume = current.Volume
+		changed = true
+	}
+
+	previousDisplays := make(map[string]base.Display)
+	for _, display := range previous.Displays {
+		previousDisplays[display.Name] = display
+	}
+
+	for _, display := range current.Displays {
+		old, ok := previousDisplays[display.Name]
+		if !ok {
+			delta.Displays = append(delta.Displays, display)
+			continue
+		}
+
+		diff := base.Display{Device: base.Device{Name: display.Name}}
+		displayChanged := false
+		if old.Power != display.Power {
+			diff.Power = display.Power
+			displayChanged = true
+		}
+		if old.Input != display.Input {
+			diff.Input = display.Input
+			displayChanged = true
+		}
+		if !boolPointersEqual(old.Blanked, display.Blanked) {
+			diff.Blanked = display.Blanked
+			displayChanged = true
+		}
+		if old.Unreachable != display.Unreachable {
+			diff.Unreachable = display.Unreachable
+			displayChanged = true
+		}
+
+		if displayChanged {
+			delta.Displays = append(delta.Displays, diff)
+		}
+	}
+
+	previousAudioDevices := make(map[string]base.AudioDevice)
+	for _, audioDevice := range previous.AudioDevices {
+		previousAudioDevices[audioDevice.Name] = audioDevice
+	}
+
+	for _, audioDevice := range current.AudioDevices {
+		old, ok := previousAudioDevices[audioDevice.Name]
+		if !ok {
+			delta.AudioDevices = append(delta.AudioDevices, audioDevice)
+			continue
+		}
+
+		diff := base.AudioDevice{Device: base.Device{Name: audioDevice.Name}}
+		audioDeviceChanged := false
+		if old.Power != audioDevice.Power {
+			diff.Power = audioDevice.Power
+			audioDeviceChanged = true
+		}
+		if old.Input != audioDevice.Input {
+			diff.Input = audioDevice.Input
+			audioDeviceChanged = true
+		}
+		if !boolPointersEqual(old.Muted, audioDevice.Muted) {
+			diff.Muted = audioDevice.Muted
+			audioDeviceChanged = true
+		}
+		if !intPointersEqual(old.Volume, audioDevice.Volume) {
+			diff.Volume = audioDevice.Volume
+			audioDeviceChanged = true
+		}
+		if old.Unreachable != audioDevice.Unreachable {
+			diff.Unreachable = audioDevice.Unreachable
+			audioDeviceChanged = true
+		}
+
+		if audioDeviceChanged {
+			delta.AudioDevices = append(delta.AudioDevices, diff)
+		}
+	}
+
+	changed = changed || len(delta.Displays) > 0 || len(delta.AudioDevices) > 0
+
+	return delta, changed
+}
+
+// RemovedDevices returns the names of the displays and audio devices in previous that aren't in current.
+func RemovedDevices(previous base.PublicRoom, current base.PublicRoom) []string {
+	var removed []string
+	seen := make(map[string]bool)
+
+	remove := func(name string, ok bool) {
+		if !ok && !seen[name] {
+			seen[name] = true
+			removed = append(removed, name)
+		}
+	}
+
+	for _, display := range previous.Displays {
+		_, ok := findDisplay(current, display.Name)
+		remove(display.Name, ok)
+	}
+
+	for _, audioDevice := range previous.AudioDevices {
+		_, ok := findAudioDevice(current, audioDevice.Name)
+		remove(audioDevice.Name, ok)
+	}
+
+	return removed
+}
+
+// overlayPublicRoom returns last with each field that room reports replaced, adding any device last doesn't have.
+func overlayPublicRoom(last base.PublicRoom, room base.PublicRoom) base.PublicRoom {
+	overlaid := last
+	overlaid.Displays = append([]base.Display(nil), last.Displays...)
+	overlaid.AudioDevices = append([]base.AudioDevice(nil), last.AudioDevices...)
+
+	if len(room.CurrentVideoInput) > 0 {
+		overlaid.CurrentVideoInput = room.CurrentVideoInput
+	}
+	if len(room.CurrentAudioInput) > 0 {
+		overlaid.CurrentAudioInput = room.CurrentAudioInput
+	}
+	if len(room.Power) > 0 {
+		overlaid.Power = room.Power
+	}
+	if room.Blanked != nil {
+		overlaid.Blanked = room.Blanked
+	}
+	if room.Muted != nil {
+		overlaid.Muted = room.Muted
+	}
+	if room.Volume != nil {
+		overlaid.Volume = room.Volume
+	}
+
+	for _, display := range room.Displays {
+		i := indexOfDisplay(overlaid.Displays, display.Name)
+		if i == -1 {
+			overlaid.Displays = append(overlaid.Displays, display)
+			continue
+		}
+
+		old := &overlaid.Displays[i]
+		if len(display.Power) > 0 {
+			old.Power = display.Power
+		}
+		if len(display.Input) > 0 {
+			old.Input = display.Input
+		}
+		if display.Blanked != nil {
+			old.Blanked = display.Blanked
+		}
+		old.Unreachable = display.Unreachable
+	}
+
+	for _, audioDevice := range room.AudioDevices {
+		i := indexOfAudioDevice(overlaid.AudioDevices, audioDevice.Name)
+		if i == -1 {
+			overlaid.AudioDevices = append(overlaid.AudioDevices, audioDevice)
+			continue
+		}
+
+		old := &overlaid.AudioDevices[i]
+		if len(audioDevice.Power) > 0 {
+			old.Power = audioDevice.Power
+		}
+		if len(audioDevice.Input) > 0 {
+			old.Input = audioDevice.Input
+		}
+		if audioDevice.Muted != nil {
+			old.Muted = audioDevice.Muted
+		}
+		if audioDevice.Volume != nil {
+			old.Volume = audioDevice.Volume
+		}
+		old.Unreachable = audioDevice.Unreachable
+	}
+
+	return overlaid
+}
+
+func boolPointersEqual(a *bool, b *bool) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+func intPointersEqual(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
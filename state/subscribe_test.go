@@ -0,0 +1,149 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/common/v2/events"
+)
+
+func TestDiffPublicRoomOnlyReportsChangedFields(t *testing.T) {
+	blanked, unblanked := true, false
+	low, high := 10, 30
+
+	previous := base.PublicRoom{
+		Displays: []base.Display{
+			{Device: base.Device{Name: "D1", Power: "on", Input: "HDMI1"}, Blanked: &unblanked},
+			{Device: base.Device{Name: "D2", Power: "on", Input: "HDMI1"}, Blanked: &unblanked},
+		},
+		AudioDevices: []base.AudioDevice{
+			{Device: base.Device{Name: "D1", Power: "on"}, Volume: &low},
+		},
+	}
+	current := base.PublicRoom{
+		Displays: []base.Display{
+			{Device: base.Device{Name: "D1", Power: "on", Input: "HDMI1"}, Blanked: &unblanked},
+			{Device: base.Device{Name: "D2", Power: "on", Input: "HDMI1"}, Blanked: &blanked},
+		},
+		AudioDevices: []base.AudioDevice{
+			{Device: base.Device{Name: "D1", Power: "on"}, Volume: &high},
+		},
+	}
+
+	delta, changed := DiffPublicRoom(previous, current)
+	if !changed {
+		t.Fatal("expected a change to be detected")
+	}
+	if len(delta.Displays) != 1 || delta.Displays[0].Name != "D2" {
+		t.Fatalf("expected only D2 in display delta, got %+v", delta.Displays)
+	}
+	if delta.Displays[0].Power != "" || delta.Displays[0].Blanked == nil || !*delta.Displays[0].Blanked {
+		t.Fatalf("expected only blanked in D2 delta, got %+v", delta.Displays[0])
+	}
+	if len(delta.AudioDevices) != 1 || delta.AudioDevices[0].Volume == nil || *delta.AudioDevices[0].Volume != high {
+		t.Fatalf("expected volume change in audio delta, got %+v", delta.AudioDevices)
+	}
+
+	if _, changed := DiffPublicRoom(current, current); changed {
+		t.Fatal("expected no change between identical rooms")
+	}
+}
+
+func TestPublicRoomFromEventMapsVolumeToAudioDevice(t *testing.T) {
+	e := events.Event{
+		Key:          "volume",
+		Value:        "45",
+		TargetDevice: events.GenerateBasicDeviceInfo("ITB-1101-D1"),
+		AffectedRoom: events.GenerateBasicRoomInfo("ITB-1101"),
+	}
+
+	room, ok := publicRoomFromEvent(e, base.DestinationDevice{AudioDevice: true, Display: true})
+	if !ok {
+		t.Fatal("expected volume event to produce an update")
+	}
+	if len(room.Displays) != 0 {
+		t.Fatalf("expected no display update for volume, got %+v", room.Displays)
+	}
+	if len(room.AudioDevices) != 1 || room.AudioDevices[0].Name != "D1" || *room.AudioDevices[0].Volume != 45 {
+		t.Fatalf("unexpected audio device update %+v", room.AudioDevices)
+	}
+}
+
+func TestRemovedDevicesReportsDevicesMissingFromCurrent(t *testing.T) {
+	previous := base.PublicRoom{
+		Displays:     []base.Display{{Device: base.Device{Name: "D1"}}, {Device: base.Device{Name: "D2"}}},
+		AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D2"}}, {Device: base.Device{Name: "MIC1"}}},
+	}
+	current := base.PublicRoom{
+		Displays: []base.Display{{Device: base.Device{Name: "D1"}}},
+	}
+
+	removed := RemovedDevices(previous, current)
+	if len(removed) != 2 || removed[0] != "D2" || removed[1] != "MIC1" {
+		t.Fatalf("expected D2 and MIC1 to be removed, got %v", removed)
+	}
+
+	if removed := RemovedDevices(current, previous); len(removed) != 0 {
+		t.Fatalf("expected no devices to be removed, got %v", removed)
+	}
+}
+
+func TestPublishRoomStateChangeSendsADiffAndUpdatesTheLastState(t *testing.T) {
+	low, high := 10, 30
+	last := base.PublicRoom{
+		Displays:     []base.Display{{Device: base.Device{Name: "D1", Power: "on", Input: "HDMI1"}}},
+		AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D1", Power: "on"}, Volume: &low}},
+	}
+
+	updates := make(chan RoomStateUpdate, 2)
+	feed := &roomStateFeed{
+		subscribers: map[chan RoomStateUpdate]struct{}{updates: {}},
+		last:        &last,
+		cancel:      func() {},
+	}
+
+	roomStateFeeds.Lock()
+	roomStateFeeds.rooms["FEED-1"] = feed
+	roomStateFeeds.Unlock()
+	defer func() {
+		roomStateFeeds.Lock()
+		delete(roomStateFeeds.rooms, "FEED-1")
+		roomStateFeeds.Unlock()
+	}()
+
+	// a set's report includes fields that didn't change
+	publishRoomStateChange("FEED-1", UpdateSourceSet, base.PublicRoom{
+		Displays:     []base.Display{{Device: base.Device{Name: "D1", Power: "on"}}},
+		AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D1"}, Volume: &high}},
+	})
+
+	update := <-updates
+	if update.Source != UpdateSourceSet || len(update.Room.Displays) != 0 {
+		t.Fatalf("expected only the volume change, got %+v", update)
+	}
+	if len(update.Room.AudioDevices) != 1 || *update.Room.AudioDevices[0].Volume != high {
+		t.Fatalf("expected D1's new volume, got %+v", update.Room.AudioDevices)
+	}
+
+	roomStateFeeds.Lock()
+	updated := *feed.last
+	roomStateFeeds.Unlock()
+
+	if d1 := updated.Displays[0]; d1.Input != "HDMI1" || d1.Power != "on" {
+		t.Fatalf("expected D1 to keep the fields the report didn't have, got %+v", d1)
+	}
+	if _, changed := DiffPublicRoom(updated, base.PublicRoom{
+		Displays:     last.Displays,
+		AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D1", Power: "on"}, Volume: &high}},
+	}); changed {
+		t.Fatalf("expected the next poll not to send the change again, got %+v", updated)
+	}
+
+	// nothing is sent when the report matches the last state
+	publishRoomStateChange("FEED-1", UpdateSourceSet, base.PublicRoom{AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D1"}, Volume: &high}}})
+	select {
+	case update := <-updates:
+		t.Fatalf("expected no update, got %+v", update)
+	default:
+	}
+}
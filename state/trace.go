@@ -0,0 +1,161 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/transport"
+)
+
+// traceResponseLimit caps how much of each response body is kept in a trace.
+const traceResponseLimit = 4096
+
+// tracer collects the trace of a room state change. Its methods do nothing on a nil tracer, so callers don't need to check whether tracing is on.
+type tracer struct {
+	mu    sync.Mutex
+	trace base.Trace
+}
+
+type tracerKey struct{}
+
+func withTracer(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tracerKey{}, &tracer{
+		trace: base.Trace{Started: time.Now()},
+	})
+}
+
+func tracerFromContext(ctx context.Context) *tracer {
+	t, _ := ctx.Value(tracerKey{}).(*tracer)
+	return t
+}
+
+func (t *tracer) evaluator(evaluator string, started time.Time, actions int, err error) {
+	if t == nil {
+		return
+	}
+
+	trace := base.EvaluatorTrace{
+		Evaluator: evaluator,
+		Started:   started,
+		Finished:  time.Now(),
+		Actions:   actions,
+	}
+	if err != nil {
+		trace.Error = err.Error()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.trace.Evaluators = append(t.trace.Evaluators, trace)
+}
+
+func (t *tracer) reconciler(reconciler string, started time.Time, DAG []base.ActionStructure, count int, err error) {
+	if t == nil {
+		return
+	}
+
+	trace := &base.ReconcilerTrace{
+		Reconciler: reconciler,
+		Started:    started,
+		Finished:   time.Now(),
+		Count:      count,
+	}
+	if err != nil {
+		trace.Error = err.Error()
+	}
+	if len(DAG) > 0 {
+		trace.Actions = BuildRoomStatePlan(DAG, count).Actions
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.trace.Reconciler = trace
+}
+
+// sent records the response to an action's command.
+func (t *tracer) sent(action base.ActionStructure, url string, started time.Time, resp transport.Response, err error) {
+	if t == nil {
+		return
+	}
+
+	finished := time.Now()
+	trace := actionTrace(action)
+	trace.URL = url
+	trace.Started = &started
+	trace.Finished = &finished
+	trace.StatusCode = resp.StatusCode
+
+	body := resp.Body
+	if len(body) > traceResponseLimit {
+		body = body[:traceResponseLimit]
+	}
+	trace.Response = string(body)
+
+	if err != nil {
+		trace.Error = err.Error()
+	}
+
+	t.add(trace)
+}
+
+// skipped records an action that wasn't sent, along with every child that won't run because of it.
+func (t *tracer) skipped(action base.ActionStructure, reason string) {
+	if t == nil {
+		return
+	}
+
+	trace := actionTrace(action)
+	trace.Skipped = true
+	trace.Reason = reason
+	t.add(trace)
+
+	for _, child := range action.Children {
+		t.skipped(*child, fmt.Sprintf("parent action %s on %s was skipped", action.Action, action.Device.ID))
+	}
+}
+
+func actionTrace(action base.ActionStructure) base.ActionTrace {
+	return base.ActionTrace{
+		Action:            action.Action,
+		Device:            action.Device.ID,
+		DestinationDevice: action.DestinationDevice.ID,
+	}
+}
+
+func (t *tracer) add(trace base.ActionTrace) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.trace.Actions = append(t.trace.Actions, trace)
+}
+
+// finish returns the collected trace, with actions ordered by when they started. Skipped actions come last.
+func (t *tracer) finish() *base.Trace {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	trace := t.trace
+	trace.Finished = time.Now()
+	trace.Actions = append([]base.ActionTrace(nil), t.trace.Actions...)
+
+	sort.SliceStable(trace.Actions, func(i, j int) bool {
+		a, b := trace.Actions[i], trace.Actions[j]
+		if a.Started == nil || b.Started == nil {
+			return a.Started != nil
+		}
+
+		return a.Started.Before(*b.Started)
+	})
+
+	return &trace
+}
@@ -0,0 +1,98 @@
+package state
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/common/structs"
+)
+
+func TestExecuteActionsRecordsTrace(t *testing.T) {
+	t.Setenv("ROOM_SYSTEM", "true")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"power":"on"}`))
+	}))
+	defer server.Close()
+
+	device := structs.Device{
+		ID:      "TEST-TRACE-D1",
+		Name:    "D1",
+		Address: "10.0.0.1",
+		Type: structs.DeviceType{
+			Commands: []structs.Command{
+				statusCommand("PowerOn", server.URL, "/:address/power/on"),
+				statusCommand("ChangeInput", server.URL, "/:address/input/:port"),
+				statusCommand("BlankDisplay", server.URL, "/:address/display/blank"),
+			},
+		},
+	}
+	defer recordDeviceSuccess(device)
+
+	input := base.ActionStructure{Action: "ChangeInput", Device: device, Parameters: map[string]string{"port": "hdmi1"}}
+	power := base.ActionStructure{Action: "PowerOn", Device: device}
+	blank := base.ActionStructure{Action: "BlankDisplay", Device: device, Overridden: true, Children: []*base.ActionStructure{&input}}
+	DAG := []base.ActionStructure{
+		{Action: "Start", Overridden: true, Children: []*base.ActionStructure{&power, &blank}},
+		power,
+		blank,
+		input,
+	}
+
+	ctx := withTracer(context.Background())
+	if _, err := ExecuteActionsWithContext(ctx, DAG, "test"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	trace := tracerFromContext(ctx).finish()
+	if len(trace.Actions) != 3 {
+		t.Fatalf("expected 3 traced actions, got %+v", trace.Actions)
+	}
+
+	actions := make(map[string]base.ActionTrace)
+	for _, action := range trace.Actions {
+		actions[action.Action] = action
+	}
+
+	sent := actions["PowerOn"]
+	if sent.Skipped || sent.StatusCode != http.StatusOK || sent.Response != `{"power":"on"}` || sent.URL != server.URL+"/10.0.0.1/power/on" {
+		t.Fatalf("unexpected trace for sent action: %+v", sent)
+	}
+	if sent.Started == nil || sent.Finished == nil || sent.Finished.Before(*sent.Started) {
+		t.Fatalf("expected start and end times for sent action: %+v", sent)
+	}
+
+	if !actions["BlankDisplay"].Skipped || actions["BlankDisplay"].Reason != "overridden" {
+		t.Fatalf("expected overridden action to be skipped: %+v", actions["BlankDisplay"])
+	}
+	if !actions["ChangeInput"].Skipped || len(actions["ChangeInput"].Reason) == 0 {
+		t.Fatalf("expected child of a skipped action to be skipped with a reason: %+v", actions["ChangeInput"])
+	}
+
+	if trace.Actions[0].Action != "PowerOn" {
+		t.Fatalf("expected sent actions before skipped ones, got %+v", trace.Actions)
+	}
+
+	// the reconciled DAG is traced the way it's planned
+	tracerFromContext(ctx).reconciler("Default", time.Now(), DAG, 3, nil)
+	reconciled := tracerFromContext(ctx).finish().Reconciler
+	if reconciled == nil || len(reconciled.Actions) != 2 {
+		t.Fatalf("expected the start action's children to be traced, got %+v", reconciled)
+	}
+	if planned := BuildRoomStatePlan(DAG, 3).Actions; reconciled.Actions[0].URL != planned[0].URL || len(reconciled.Actions[1].Children) != 1 {
+		t.Fatalf("expected the traced DAG to match the plan %+v, got %+v", planned, reconciled.Actions)
+	}
+}
+
+func TestTracerIsOptional(t *testing.T) {
+	var trace *tracer
+	trace.skipped(base.ActionStructure{Action: "PowerOn"}, "overridden")
+
+	if trace.finish() != nil {
+		t.Fatal("expected no trace when tracing is off")
+	}
+}
@@ -0,0 +1,217 @@
+package state
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/byuoitav/av-api/base"
+	se "github.com/byuoitav/av-api/statusevaluators"
+	"github.com/byuoitav/common/log"
+	"github.com/byuoitav/common/structs"
+	"github.com/fatih/color"
+)
+
+// VerifyRoomState reads back the state of the devices touched by an executed DAG and returns the requested fields they don't report.
+func VerifyRoomState(ctx context.Context, room structs.Room, target base.PublicRoom, DAG []base.ActionStructure) ([]base.Mismatch, error) {
+	log.L.Infof("%s", color.HiBlueString("[state] verifying room state..."))
+
+	commands, err := verificationCommands(room, DAG)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(commands) == 0 {
+		log.L.Infof("[state] no status commands to verify %s with", room.ID)
+		return nil, nil
+	}
+
+	responses, err := RunStatusCommandsWithContext(ctx, commands)
+	if err != nil {
+		return nil, err
+	}
+
+	readback, err := EvaluateResponsesWithContext(ctx, room, responses, len(commands))
+	if err != nil {
+		return nil, err
+	}
+
+	return CompareRoomState(target, readback), nil
+}
+
+// verificationCommands returns the room's status commands that read back what the actions in a DAG changed.
+func verificationCommands(room structs.Room, DAG []base.ActionStructure) ([]se.StatusCommand, error) {
+	devices := make(map[string]bool)
+	generators := make(map[string]bool)
+
+	var walk func(action base.ActionStructure)
+	walk = func(action base.ActionStructure) {
+		if generator, ok := SET_STATE_STATUS_EVALUATORS[action.GeneratingEvaluator]; ok {
+			generators[generator] = true
+			devices[action.Device.ID] = true
+			devices[action.DestinationDevice.ID] = true
+		}
+
+		for _, child := range action.Children {
+			walk(*child)
+		}
+	}
+
+	for _, action := range DAG {
+		walk(action)
+	}
+	delete(devices, "")
+
+	commands, _, err := GenerateStatusCommands(room, se.StatusEvaluatorMap)
+	if err != nil {
+		return nil, err
+	}
+
+	var output []se.StatusCommand
+	for _, command := range commands {
+		if !generators[command.Generator] {
+			continue
+		}
+
+		if devices[command.Device.ID] || devices[command.DestinationDevice.ID] {
+			output = append(output, command)
+		}
+	}
+
+	return output, nil
+}
+
+// CompareRoomState returns the fields requested in target that readback doesn't match.
+// Room-wide power, blanked, muted, and volume are checked against every device read back; inputs are only checked per device.
+func CompareRoomState(target base.PublicRoom, readback base.PublicRoom) []base.Mismatch {
+	var mismatches []base.Mismatch
+
+	requestedDisplays := make(map[string]base.Display)
+	for _, display := range target.Displays {
+		requestedDisplays[strings.ToLower(display.Name)] = display
+	}
+
+	requestedAudioDevices := make(map[string]base.AudioDevice)
+	for _, audioDevice := range target.AudioDevices {
+		requestedAudioDevices[strings.ToLower(audioDevice.Name)] = audioDevice
+	}
+
+	readDisplays := make(map[string]base.Display)
+	for _, display := range readback.Displays {
+		readDisplays[strings.ToLower(display.Name)] = display
+	}
+
+	readAudioDevices := make(map[string]base.AudioDevice)
+	for _, audioDevice := range readback.AudioDevices {
+		readAudioDevices[strings.ToLower(audioDevice.Name)] = audioDevice
+	}
+
+	// room-wide fields apply to every device read back, unless that device was given its own value
+	for name, display := range readDisplays {
+		requested := requestedDisplays[name]
+		if len(requested.Power) == 0 {
+			requested.Power = target.Power
+		}
+		if requested.Blanked == nil {
+			requested.Blanked = target.Blanked
+		}
+		if len(requested.Name) == 0 {
+			requested.Name = display.Name
+		}
+
+		requestedDisplays[name] = requested
+	}
+
+	for name, audioDevice := range readAudioDevices {
+		requested := requestedAudioDevices[name]
+		if len(requested.Power) == 0 {
+			requested.Power = target.Power
+		}
+		if requested.Muted == nil {
+			requested.Muted = target.Muted
+		}
+		if requested.Volume == nil {
+			requested.Volume = target.Volume
+		}
+		if len(requested.Name) == 0 {
+			requested.Name = audioDevice.Name
+		}
+
+		requestedAudioDevices[name] = requested
+	}
+
+	for name, requested := range requestedDisplays {
+		actual, ok := readDisplays[name]
+
+		if len(requested.Power) > 0 && (!ok || !strings.EqualFold(requested.Power, actual.Power)) {
+			mismatches = append(mismatches, mismatch(requested.Name, "power", requested.Power, actual.Power, ok))
+		}
+		if len(requested.Input) > 0 && (!ok || !strings.EqualFold(requested.Input, actual.Input)) {
+			mismatches = append(mismatches, mismatch(requested.Name, "input", requested.Input, actual.Input, ok))
+		}
+		if requested.Blanked != nil && (!ok || !boolPointersEqual(requested.Blanked, actual.Blanked)) {
+			mismatches = append(mismatches, mismatch(requested.Name, "blanked", *requested.Blanked, actual.Blanked, ok))
+		}
+	}
+
+	for name, requested := range requestedAudioDevices {
+		actual, ok := readAudioDevices[name]
+
+		if len(requested.Power) > 0 && (!ok || !strings.EqualFold(requested.Power, actual.Power)) {
+			mismatches = append(mismatches, mismatch(requested.Name, "power", requested.Power, actual.Power, ok))
+		}
+		if len(requested.Input) > 0 && (!ok || !strings.EqualFold(requested.Input, actual.Input)) {
+			mismatches = append(mismatches, mismatch(requested.Name, "input", requested.Input, actual.Input, ok))
+		}
+		if requested.Muted != nil && (!ok || !boolPointersEqual(requested.Muted, actual.Muted)) {
+			mismatches = append(mismatches, mismatch(requested.Name, "muted", *requested.Muted, actual.Muted, ok))
+		}
+		if requested.Volume != nil && (!ok || !intPointersEqual(requested.Volume, actual.Volume)) {
+			mismatches = append(mismatches, mismatch(requested.Name, "volume", *requested.Volume, actual.Volume, ok))
+		}
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		if mismatches[i].Device != mismatches[j].Device {
+			return mismatches[i].Device < mismatches[j].Device
+		}
+
+		return mismatches[i].Field < mismatches[j].Field
+	})
+
+	for _, m := range mismatches {
+		log.L.Warnf("%s", color.HiYellowString("[state] %s reported %s %v, expected %v", m.Device, m.Field, m.Actual, m.Expected))
+	}
+
+	return mismatches
+}
+
+// mismatch builds a Mismatch, leaving Actual empty when the device wasn't read back at all.
+func mismatch(device string, field string, expected interface{}, actual interface{}, readBack bool) base.Mismatch {
+	m := base.Mismatch{
+		Device:   device,
+		Field:    field,
+		Expected: expected,
+	}
+
+	if !readBack {
+		return m
+	}
+
+	switch v := actual.(type) {
+	case *bool:
+		if v != nil {
+			m.Actual = *v
+		}
+	case *int:
+		if v != nil {
+			m.Actual = *v
+		}
+	case string:
+		if len(v) > 0 {
+			m.Actual = v
+		}
+	}
+
+	return m
+}
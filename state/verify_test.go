@@ -0,0 +1,82 @@
+package state
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/byuoitav/av-api/base"
+)
+
+func TestCompareRoomStateReportsMismatchedFields(t *testing.T) {
+	on, off := true, false
+	volume, readVolume := 40, 25
+
+	target := base.PublicRoom{
+		Power:   "on",
+		Blanked: &off,
+		Displays: []base.Display{
+			{Device: base.Device{Name: "D1", Input: "HDMI1"}},
+			{Device: base.Device{Name: "D3", Power: "standby"}},
+		},
+		AudioDevices: []base.AudioDevice{
+			{Device: base.Device{Name: "D1"}, Volume: &volume},
+		},
+	}
+
+	readback := base.PublicRoom{
+		Displays: []base.Display{
+			{Device: base.Device{Name: "D1", Power: "on", Input: "hdmi1"}, Blanked: &off},
+			{Device: base.Device{Name: "D2", Power: "standby", Input: "HDMI2"}, Blanked: &on},
+		},
+		AudioDevices: []base.AudioDevice{
+			{Device: base.Device{Name: "D1", Power: "on"}, Muted: &off, Volume: &readVolume},
+		},
+	}
+
+	got := CompareRoomState(target, readback)
+	want := []base.Mismatch{
+		{Device: "D1", Field: "volume", Expected: 40, Actual: 25},
+		{Device: "D2", Field: "blanked", Expected: false, Actual: true},
+		{Device: "D2", Field: "power", Expected: "on", Actual: "standby"},
+		{Device: "D3", Field: "power", Expected: "standby"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected mismatches %+v, got %+v", want, got)
+	}
+}
+
+func TestCompareRoomStateMatches(t *testing.T) {
+	muted := true
+	target := base.PublicRoom{Muted: &muted}
+	readback := base.PublicRoom{
+		AudioDevices: []base.AudioDevice{{Device: base.Device{Name: "D1"}, Muted: &muted}},
+	}
+
+	if got := CompareRoomState(target, readback); len(got) != 0 {
+		t.Fatalf("expected no mismatches, got %+v", got)
+	}
+}
+
+func TestSetRoomStateLatestCarriesOptionsToJob(t *testing.T) {
+	originalSetRoomStateWithContext := setRoomStateWithContext
+	defer func() {
+		setRoomStateWithContext = originalSetRoomStateWithContext
+	}()
+
+	var options SetRoomStateOptions
+	setRoomStateWithContext = func(ctx context.Context, target base.PublicRoom, requestor string) (base.PublicRoom, error) {
+		options = setRoomStateOptionsFromContext(ctx)
+		return target, nil
+	}
+
+	ctx := WithSetRoomStateOptions(context.Background(), SetRoomStateOptions{Verify: true})
+	if _, err := SetRoomStateLatest(ctx, base.PublicRoom{Building: "TEST", Room: "VERIFY"}, "test"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !options.Verify {
+		t.Fatal("expected verify option to reach the job")
+	}
+}
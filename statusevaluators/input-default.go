@@ -13,7 +13,7 @@ import (
 	"github.com/byuoitav/common/status"
 
 	"github.com/byuoitav/av-api/base"
-	"github.com/byuoitav/common/db"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/common/log"
 	"github.com/byuoitav/common/structs"
 )
@@ -59,7 +59,7 @@ func (p *InputDefault) EvaluateResponse(room structs.Room, label string, value i
 	}
 
 	// match the inputID from the port to a device in the db, and return that devices' name
-	device, err := db.GetDB().GetDevice(inputID)
+	device, err := config.GetDevice(inputID)
 
 	inputValue := device.Name
 
@@ -3,9 +3,9 @@ package statusevaluators
 import (
 	"strings"
 
-	"github.com/byuoitav/common/db"
 
 	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/common/log"
 	"github.com/byuoitav/common/structs"
 )
@@ -82,7 +82,7 @@ func generateStandardStatusCommand(devices []structs.Device, evaluatorName strin
 				if structs.HasRole(device, "MirrorMaster") {
 					for _, port := range device.Ports {
 						if port.ID == "mirror" {
-							DX, err := db.GetDB().GetDevice(port.DestinationDevice)
+							DX, err := config.GetDevice(port.DestinationDevice)
 							if err != nil {
 								return output, count, err
 							}
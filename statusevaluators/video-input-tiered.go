@@ -9,10 +9,10 @@ import (
 	"sync"
 	"time"
 
-	"github.com/byuoitav/common/db"
 	"github.com/byuoitav/common/status"
 
 	"github.com/byuoitav/av-api/base"
+	"github.com/byuoitav/av-api/config"
 	"github.com/byuoitav/av-api/statusevaluators/pathfinder"
 	"github.com/byuoitav/common/log"
 	"github.com/byuoitav/common/structs"
@@ -131,7 +131,7 @@ func (p *InputTieredSwitcher) GenerateCommands(room structs.Room) ([]StatusComma
 	callbackEngine.SetDevices(room.Devices)
 
 	for id, port := range mirrorEdges {
-		device, _ := db.GetDB().GetDevice(id)
+		device, _ := config.GetDevice(id)
 		callbackEngine.AddEdge(device, port)
 	}
 
@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+const maxResponseSize = 1 << 20
+
+// HTTP sends commands to a device microservice. GET requests carry their parameters in the URL;
+// any other method also sends the parameters as a JSON body.
+type HTTP struct {
+	Method string
+}
+
+// Send makes the request and reads up to 1MB of the response.
+func (h *HTTP) Send(ctx context.Context, request Request) (Response, error) {
+	var body io.Reader
+	if h.Method != http.MethodGet {
+		b, err := json.Marshal(request.Parameters)
+		if err != nil {
+			return Response{}, err
+		}
+
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, h.Method, request.URL, body)
+	if err != nil {
+		return Response{}, err
+	}
+
+	for key, values := range request.Header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{Timeout: request.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return Response{StatusCode: resp.StatusCode}, err
+	}
+
+	return Response{StatusCode: resp.StatusCode, Body: b}, nil
+}
@@ -0,0 +1,144 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/byuoitav/common/structs"
+)
+
+// Names of the built in transports.
+const (
+	HTTPGet  = "http-get"
+	HTTPPost = "http-post"
+	HTTPPut  = "http-put"
+	Driver   = "driver"
+)
+
+// TagPrefix marks a command or microservice tag that selects a transport, e.g. "transport:http-post".
+const TagPrefix = "transport:"
+
+// DriverScheme is the URL scheme of microservice addresses that are handled by an in-process driver, e.g. "driver://sony-bravia".
+const DriverScheme = "driver"
+
+// Request is a command to send to a device.
+type Request struct {
+	Device     structs.Device
+	Command    structs.Command
+	URL        string
+	Parameters map[string]string
+	Header     http.Header
+	Timeout    time.Duration
+}
+
+// Response is what a device (or its microservice) returned for a command.
+type Response struct {
+	StatusCode int
+	Body       []byte
+}
+
+// Transport sends commands to devices.
+type Transport interface {
+	Send(ctx context.Context, request Request) (Response, error)
+}
+
+var registry = struct {
+	sync.RWMutex
+	transports map[string]Transport
+	drivers    map[string]Transport
+}{
+	transports: map[string]Transport{
+		HTTPGet:  &HTTP{Method: http.MethodGet},
+		HTTPPost: &HTTP{Method: http.MethodPost},
+		HTTPPut:  &HTTP{Method: http.MethodPut},
+		Driver:   &drivers{},
+	},
+	drivers: make(map[string]Transport),
+}
+
+// Register adds a transport that commands can select by name.
+func Register(name string, transport Transport) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.transports[name] = transport
+}
+
+// RegisterDriver links an in-process driver into the API. Commands whose microservice address is driver://<name> are sent to it.
+func RegisterDriver(name string, driver Transport) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.drivers[name] = driver
+}
+
+// Select picks the transport for a command. A transport tag on the command wins over one on its microservice;
+// otherwise driver:// addresses use the driver registry and everything else uses an HTTP GET.
+func Select(command structs.Command, address string) (Transport, error) {
+	name := tagged(command.Tags)
+	if len(name) == 0 {
+		name = tagged(command.Microservice.Tags)
+	}
+
+	if len(name) == 0 {
+		name = HTTPGet
+
+		if u, err := url.Parse(address); err == nil && u.Scheme == DriverScheme {
+			name = Driver
+		}
+	}
+
+	registry.RLock()
+	defer registry.RUnlock()
+
+	transport, ok := registry.transports[name]
+	if !ok {
+		return nil, fmt.Errorf("no transport registered for %q", name)
+	}
+
+	return transport, nil
+}
+
+// Send selects the transport for the request's command and sends the request with it.
+func Send(ctx context.Context, request Request) (Response, error) {
+	transport, err := Select(request.Command, request.URL)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return transport.Send(ctx, request)
+}
+
+func tagged(tags []string) string {
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, TagPrefix) {
+			return strings.TrimPrefix(tag, TagPrefix)
+		}
+	}
+
+	return ""
+}
+
+type drivers struct{}
+
+func (*drivers) Send(ctx context.Context, request Request) (Response, error) {
+	u, err := url.Parse(request.URL)
+	if err != nil {
+		return Response{}, err
+	}
+
+	registry.RLock()
+	driver, ok := registry.drivers[u.Host]
+	registry.RUnlock()
+
+	if !ok {
+		return Response{}, fmt.Errorf("no driver registered for %q", u.Host)
+	}
+
+	return driver.Send(ctx, request)
+}
@@ -0,0 +1,83 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/byuoitav/common/structs"
+)
+
+type fakeDriver struct {
+	requests []Request
+}
+
+func (f *fakeDriver) Send(ctx context.Context, request Request) (Response, error) {
+	f.requests = append(f.requests, request)
+	return Response{StatusCode: http.StatusOK, Body: []byte(`{"power":"on"}`)}, nil
+}
+
+func TestSendPostsParametersAsJSON(t *testing.T) {
+	var method string
+	var body map[string]string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		b, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(b, &body)
+		_, _ = w.Write([]byte(`{"volume":30}`))
+	}))
+	defer server.Close()
+
+	response, err := Send(context.Background(), Request{
+		Command:    structs.Command{ID: "SetVolume", Tags: []string{TagPrefix + HTTPPost}},
+		URL:        server.URL + "/volume",
+		Parameters: map[string]string{"level": "30"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if method != http.MethodPost {
+		t.Fatalf("expected POST, got %s", method)
+	}
+	if body["level"] != "30" {
+		t.Fatalf("expected parameters in the body, got %v", body)
+	}
+	if string(response.Body) != `{"volume":30}` {
+		t.Fatalf("unexpected response body %s", response.Body)
+	}
+}
+
+func TestSelectPrefersCommandTagsAndDrivers(t *testing.T) {
+	command := structs.Command{
+		Tags:         []string{TagPrefix + HTTPPut},
+		Microservice: structs.Microservice{Tags: []string{TagPrefix + HTTPPost}},
+	}
+
+	selected, err := Select(command, "http://localhost:8005/power")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if h, ok := selected.(*HTTP); !ok || h.Method != http.MethodPut {
+		t.Fatalf("expected the command's PUT transport, got %#v", selected)
+	}
+
+	if _, err := Select(structs.Command{Tags: []string{TagPrefix + "carrier-pigeon"}}, ""); err == nil {
+		t.Fatal("expected an unknown transport to be rejected")
+	}
+
+	driver := &fakeDriver{}
+	RegisterDriver("test-display", driver)
+
+	response, err := Send(context.Background(), Request{URL: "driver://test-display/power/on"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(driver.requests) != 1 || response.StatusCode != http.StatusOK {
+		t.Fatalf("expected the request to reach the driver, got %d requests and status %d", len(driver.requests), response.StatusCode)
+	}
+}